@@ -1 +1 @@
-yt-dlp "{url}" --no-mtime -f "bestaudio[ext=m4a]/bestaudio" --retries infinite
\ No newline at end of file
+yt-dlp {url} --no-mtime -f "bestaudio[ext=m4a]/bestaudio" --retries infinite
\ No newline at end of file
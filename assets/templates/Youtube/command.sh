@@ -1 +1 @@
-yt-dlp "{url}" --no-mtime -f "bestvideo[height<=1080][ext=mp4]+bestaudio[ext=m4a]/best[ext=mp4]/best" --retries infinite
\ No newline at end of file
+yt-dlp {url} --no-mtime -f "bestvideo[height<=1080][ext=mp4]+bestaudio[ext=m4a]/best[ext=mp4]/best" --retries infinite
\ No newline at end of file
@@ -12,6 +12,7 @@ require (
 	github.com/ncruces/zenity v0.10.12
 	github.com/skratchdot/open-golang v0.0.0-20200116055534-eef842397966
 	golang.org/x/sys v0.19.0
+	mvdan.cc/sh/v3 v3.7.0
 )
 
 require (
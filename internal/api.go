@@ -2,6 +2,7 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"goTaskQueue/internal/cfg"
 	memstorage "goTaskQueue/internal/memStorage"
@@ -9,20 +10,50 @@ import (
 	"goTaskQueue/internal/taskQueue"
 	"goTaskQueue/internal/utils"
 	"net/http"
-	"strings"
 	"syscall"
 
 	"github.com/NYTimes/gziphandler"
 )
 
 type JsonFailResponse struct {
-	Error string `json:"error"`
+	Error  string                    `json:"error"`
+	Errors []taskQueue.VariableError `json:"errors,omitempty"`
 }
 
 type JsonSuccessResponse struct {
 	Result interface{} `json:"result"`
 }
 
+type AddTaskPayload struct {
+	Command          *string                    `json:"command"`
+	Label            *string                    `json:"label"`
+	Group            *string                    `json:"group"`
+	IsPty            *bool                      `json:"isPty"`
+	IsOnlyCombined   *bool                      `json:"isOnlyCombined"`
+	IsSingleInstance *bool                      `json:"isSingleInstance"`
+	IsStartOnBoot    *bool                      `json:"isStartOnBoot"`
+	IsWriteLogs      *bool                      `json:"isWriteLogs"`
+	TemplatePlace    string                     `json:"templatePlace"`
+	TemplateId       string                     `json:"templateId"`
+	Variables        map[string]string          `json:"variables"`
+	Preset           string                     `json:"preset"`
+	IsRun            bool                       `json:"isRun"`
+	TTL              *int64                     `json:"ttl"`
+	MaxRuntime       *int64                     `json:"maxRuntime"`
+	StopSignal       *int                       `json:"stopSignal"`
+	StopTimeout      *int64                     `json:"stopTimeout"`
+	Retry            *taskQueue.RetryPolicy     `json:"retry"`
+	Priority         *int                       `json:"priority"`
+	DependsOn        []string                   `json:"dependsOn"`
+	Restart          *taskQueue.RestartPolicy   `json:"restart"`
+	Probe            *taskQueue.TaskProbe       `json:"probe"`
+	Resources        *taskQueue.TaskResources   `json:"resources"`
+	RunAs            *taskQueue.TaskCredential  `json:"runAs"`
+	WorkingDir       *string                    `json:"workingDir"`
+	Env              map[string]string          `json:"env"`
+	Interpreter      *taskQueue.TaskInterpreter `json:"interpreter"`
+}
+
 func HandleApi(router *Router, queue *taskQueue.Queue, memStorage *memstorage.MemStorage, config *cfg.Config, callChan chan string) {
 	apiRouter := NewRouter()
 	gzipHandler := gziphandler.GzipHandler(apiRouter)
@@ -50,27 +81,17 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 		IsRun bool   `json:"isRun"`
 	}
 
+	type RerunTaskPayload struct {
+		Id        string            `json:"id"`
+		Variables map[string]string `json:"variables"`
+		IsRun     bool              `json:"isRun"`
+	}
+
 	type SignalTaskPayload struct {
 		Id     string `json:"id"`
 		Signal int    `json:"signal"`
 	}
 
-	type AddTaskPayload struct {
-		Command          *string           `json:"command"`
-		Label            *string           `json:"label"`
-		Group            *string           `json:"group"`
-		IsPty            *bool             `json:"isPty"`
-		IsOnlyCombined   *bool             `json:"isOnlyCombined"`
-		IsSingleInstance *bool             `json:"isSingleInstance"`
-		IsStartOnBoot    *bool             `json:"isStartOnBoot"`
-		IsWriteLogs      *bool             `json:"isWriteLogs"`
-		TemplatePlace    string            `json:"templatePlace"`
-		TemplateId       string            `json:"templateId"`
-		Variables        map[string]string `json:"variables"`
-		IsRun            bool              `json:"isRun"`
-		TTL              *int64            `json:"ttl"`
-	}
-
 	type SetLabelPayload struct {
 		Id    string `json:"id"`
 		Label string `json:"label"`
@@ -96,6 +117,10 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 		Path string `json:"path"`
 	}
 
+	type SetDrainingPayload struct {
+		IsDraining bool `json:"isDraining"`
+	}
+
 	router.Get("/api/tasks", func(w http.ResponseWriter, r *http.Request) {
 		apiCall(w, func() ([]*taskQueue.Task, error) {
 			tasks := queue.GetAll(config)
@@ -123,50 +148,65 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 				return nil, err
 			}
 
-			var template *taskQueue.Template
-			if payload.TemplatePlace != "" {
-				template, err = taskQueue.ReadTemplate(payload.TemplatePlace)
-				if err != nil {
-					return nil, fmt.Errorf("template not found by place %v", payload.TemplatePlace)
-				}
+			taskBase, err := getAddTaskBase(config, payload)
+			if err != nil {
+				return nil, err
 			}
-			if template == nil && payload.TemplateId != "" {
-				template, err = taskQueue.GetTemplate(payload.TemplateId)
+
+			task, err := queue.Add(config, *taskBase)
+			if err != nil {
+				return nil, err
+			}
+
+			if payload.IsRun {
+				err := task.Run(config, queue)
 				if err != nil {
-					return nil, fmt.Errorf("template not found by id %v", payload.TemplateId)
+					return nil, err
 				}
 			}
-			if template == nil {
-				template = &taskQueue.Template{}
+
+			return task, err
+		})
+	})
+
+	router.Post("/api/preview", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (*taskQueue.TaskPreview, error) {
+			payload, err := utils.ParseJson[AddTaskPayload](r.Body)
+			if err != nil {
+				return nil, err
 			}
 
-			taskBase := taskQueue.TaskBase{}
-			taskBase.TemplatePlace = template.Place
+			taskBase, err := getAddTaskBase(config, payload)
+			var variablesErr *taskQueue.VariablesError
+			if err != nil && !errors.As(err, &variablesErr) {
+				return nil, err
+			}
 
-			taskBase.Command = setValue(payload.Command, template.Command)
-			taskBase.Label = setValue(payload.Label, template.Label)
-			taskBase.Group = setValue(payload.Group, template.Group)
-			taskBase.IsPty = setValue(payload.IsPty, template.IsPty)
-			taskBase.IsOnlyCombined = setValue(payload.IsOnlyCombined, template.IsOnlyCombined)
-			taskBase.IsSingleInstance = setValue(payload.IsSingleInstance, template.IsSingleInstance)
-			taskBase.IsStartOnBoot = setValue(payload.IsStartOnBoot, template.IsStartOnBoot)
-			taskBase.IsWriteLogs = setValue(payload.IsWriteLogs, template.IsWriteLogs)
-			taskBase.TTL = setValue(payload.TTL, template.TTL)
+			preview, err := queue.Preview(config, *taskBase)
+			if err != nil {
+				return nil, err
+			}
+			if variablesErr != nil {
+				preview.Errors = variablesErr.Errors
+			}
+			return preview, nil
+		})
+	})
 
-			for _, variable := range template.Variables {
-				old := fmt.Sprintf("{%v}", variable.Value)
-				value, ok := payload.Variables[variable.Value]
-				if !ok {
-					value = variable.DefaultValue
-				}
-				taskBase.Command = strings.ReplaceAll(taskBase.Command, old, value)
-				taskBase.Label = strings.ReplaceAll(taskBase.Label, old, value)
+	router.Post("/api/clone", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (*taskQueue.Task, error) {
+			payload, err := utils.ParseJson[CloneTaskPayload](r.Body)
+			if err != nil {
+				return nil, err
 			}
 
-			task := queue.Add(config, taskBase)
+			task, err := queue.Clone(config, payload.Id)
+			if err != nil {
+				return nil, err
+			}
 
 			if payload.IsRun {
-				err := task.Run(config, queue)
+				err = task.Run(config, queue)
 				if err != nil {
 					return nil, err
 				}
@@ -176,14 +216,17 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 		})
 	})
 
-	router.Post("/api/clone", func(w http.ResponseWriter, r *http.Request) {
+	router.Post("/api/rerun", func(w http.ResponseWriter, r *http.Request) {
 		apiCall(w, func() (*taskQueue.Task, error) {
-			payload, err := utils.ParseJson[CloneTaskPayload](r.Body)
+			payload, err := utils.ParseJson[RerunTaskPayload](r.Body)
 			if err != nil {
 				return nil, err
 			}
 
-			task, err := queue.Clone(config, payload.Id)
+			task, err := queue.Rerun(config, payload.Id, payload.Variables)
+			if err != nil {
+				return nil, err
+			}
 
 			if payload.IsRun {
 				err = task.Run(config, queue)
@@ -196,12 +239,34 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 		})
 	})
 
+	type TaskWithGraph struct {
+		*taskQueue.Task
+		Graph *taskQueue.TaskGraph `json:"graph"`
+	}
+
 	router.Get("/api/task", func(w http.ResponseWriter, r *http.Request) {
-		apiCall(w, func() (*taskQueue.Task, error) {
+		apiCall(w, func() (*TaskWithGraph, error) {
 			id := r.URL.Query().Get("id")
 
 			task, err := queue.Get(id)
-			return task, err
+			if err != nil {
+				return nil, err
+			}
+
+			graph, err := queue.GetGraph(id)
+			if err != nil {
+				return nil, err
+			}
+
+			return &TaskWithGraph{task, graph}, nil
+		})
+	})
+
+	router.Get("/api/task/stats", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (*taskQueue.TaskStats, error) {
+			id := r.URL.Query().Get("id")
+
+			return queue.GetStats(id)
 		})
 	})
 
@@ -351,6 +416,23 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 		})
 	})
 
+	router.Get("/api/drain", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (taskQueue.DrainState, error) {
+			return queue.GetDrainState(), nil
+		})
+	})
+
+	router.Post("/api/drain", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (taskQueue.DrainState, error) {
+			payload, err := utils.ParseJson[SetDrainingPayload](r.Body)
+			if err != nil {
+				return taskQueue.DrainState{}, err
+			}
+
+			return queue.SetDraining(payload.IsDraining), nil
+		})
+	})
+
 	router.Post("/api/reloadConfig", func(w http.ResponseWriter, r *http.Request) {
 		apiCall(w, func() (string, error) {
 			callChan <- "reload"
@@ -423,12 +505,34 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 
 	router.Get("/api/templates", func(w http.ResponseWriter, r *http.Request) {
 		apiCall(w, func() ([]taskQueue.Template, error) {
-			templates := taskQueue.GetTemplates()
+			templates := queue.WithSchedules(taskQueue.GetTemplates())
 
 			return templates, nil
 		})
 	})
 
+	type PauseSchedulePayload struct {
+		RelPlace string `json:"place"`
+		Index    int    `json:"index"`
+		IsPaused bool   `json:"isPaused"`
+	}
+
+	router.Post("/api/pauseSchedule", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (string, error) {
+			payload, err := utils.ParseJson[PauseSchedulePayload](r.Body)
+			if err != nil {
+				return "", err
+			}
+
+			err = queue.PauseSchedule(payload.RelPlace, payload.Index, payload.IsPaused)
+			if err != nil {
+				return "", err
+			}
+
+			return "ok", nil
+		})
+	})
+
 	router.Get("/api/getTemplate", func(w http.ResponseWriter, r *http.Request) {
 		apiCall(w, func() (*taskQueue.Template, error) {
 			id := r.URL.Query().Get("id")
@@ -445,6 +549,7 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 	type SetTemplatePayload struct {
 		PrevRelPlace string             `json:"prevPlace"`
 		Template     taskQueue.Template `json:"template"`
+		Comment      string             `json:"comment"`
 	}
 
 	router.Post("/api/setTemplate", func(w http.ResponseWriter, r *http.Request) {
@@ -457,13 +562,13 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 			isNew := len(payload.PrevRelPlace) == 0
 
 			if !isNew && payload.PrevRelPlace != payload.Template.Place {
-				err = taskQueue.MoveTemplate(payload.PrevRelPlace, payload.Template.Place)
+				err = taskQueue.MoveTemplate(payload.PrevRelPlace, payload.Template.Place, "")
 				if err != nil {
 					return "", err
 				}
 			}
 
-			err = taskQueue.WriteTemplate(payload.Template, isNew)
+			err = taskQueue.WriteTemplate(payload.Template, isNew, payload.Comment)
 			if err != nil {
 				return "", err
 			}
@@ -485,9 +590,91 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 		})
 	})
 
+	router.Get("/api/templateOptions", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (map[string][]string, error) {
+			relPlace := r.URL.Query().Get("place")
+
+			template, err := taskQueue.ReadTemplate(relPlace)
+			if err != nil {
+				return nil, err
+			}
+
+			template, err = taskQueue.RenderTemplate(template)
+			if err != nil {
+				return nil, err
+			}
+
+			return taskQueue.GetVariableOptions(config, template)
+		})
+	})
+
+	router.Get("/api/renderTemplate", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (*taskQueue.Template, error) {
+			relPlace := r.URL.Query().Get("place")
+
+			template, err := taskQueue.ReadTemplate(relPlace)
+			if err != nil {
+				return nil, err
+			}
+
+			return taskQueue.RenderTemplate(template)
+		})
+	})
+
+	type SetPresetPayload struct {
+		Place  string                   `json:"place"`
+		Preset taskQueue.TemplatePreset `json:"preset"`
+	}
+
+	type RemovePresetPayload struct {
+		Place string `json:"place"`
+		Name  string `json:"name"`
+	}
+
+	router.Get("/api/presets", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() ([]taskQueue.TemplatePreset, error) {
+			relPlace := r.URL.Query().Get("place")
+
+			return taskQueue.ReadPresets(relPlace)
+		})
+	})
+
+	router.Post("/api/setPreset", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (string, error) {
+			payload, err := utils.ParseJson[SetPresetPayload](r.Body)
+			if err != nil {
+				return "", err
+			}
+
+			err = taskQueue.SetPreset(payload.Place, payload.Preset)
+			if err != nil {
+				return "", err
+			}
+
+			return "ok", nil
+		})
+	})
+
+	router.Post("/api/removePreset", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (string, error) {
+			payload, err := utils.ParseJson[RemovePresetPayload](r.Body)
+			if err != nil {
+				return "", err
+			}
+
+			err = taskQueue.RemovePreset(payload.Place, payload.Name)
+			if err != nil {
+				return "", err
+			}
+
+			return "ok", nil
+		})
+	})
+
 	type MoveTemplatePayload struct {
 		RelFrom string `json:"from"`
 		RelTo   string `json:"to"`
+		Comment string `json:"comment"`
 	}
 
 	router.Post("/api/moveTemplate", func(w http.ResponseWriter, r *http.Request) {
@@ -497,7 +684,7 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 				return "", err
 			}
 
-			err = taskQueue.MoveTemplate(payload.RelFrom, payload.RelTo)
+			err = taskQueue.MoveTemplate(payload.RelFrom, payload.RelTo, payload.Comment)
 			if err != nil {
 				return "", err
 			}
@@ -524,6 +711,7 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 
 	type RemoveTemplatePayload struct {
 		RelPlace string `json:"place"`
+		Comment  string `json:"comment"`
 	}
 
 	router.Post("/api/removeTemplate", func(w http.ResponseWriter, r *http.Request) {
@@ -533,7 +721,45 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 				return "", err
 			}
 
-			err = taskQueue.RemoveTemplate(payload.RelPlace)
+			err = taskQueue.RemoveTemplate(payload.RelPlace, payload.Comment)
+			if err != nil {
+				return "", err
+			}
+
+			return "ok", nil
+		})
+	})
+
+	type RestoreTemplateRevisionPayload struct {
+		Id       string `json:"id"`
+		RelPlace string `json:"place"`
+		Comment  string `json:"comment"`
+	}
+
+	router.Get("/api/templateRevisions", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() ([]taskQueue.TemplateRevision, error) {
+			relPlace := r.URL.Query().Get("place")
+
+			return taskQueue.ListTemplateRevisions(relPlace)
+		})
+	})
+
+	router.Get("/api/templateRevisionDiff", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (*taskQueue.TemplateRevisionDiff, error) {
+			query := r.URL.Query()
+
+			return taskQueue.DiffTemplateRevisions(query.Get("from"), query.Get("to"))
+		})
+	})
+
+	router.Post("/api/restoreTemplateRevision", func(w http.ResponseWriter, r *http.Request) {
+		apiCall(w, func() (string, error) {
+			payload, err := utils.ParseJson[RestoreTemplateRevisionPayload](r.Body)
+			if err != nil {
+				return "", err
+			}
+
+			err = taskQueue.RestoreTemplateRevision(payload.Id, payload.RelPlace, payload.Comment)
 			if err != nil {
 				return "", err
 			}
@@ -543,6 +769,102 @@ func handleAction(router *Router, config *cfg.Config, queue *taskQueue.Queue, ca
 	})
 }
 
+// getAddTaskBase renders the template of the payload into the task base, it is shared by add and preview
+func getAddTaskBase(config *cfg.Config, payload *AddTaskPayload) (*taskQueue.TaskBase, error) {
+	var template *taskQueue.Template
+	var err error
+	if payload.TemplatePlace != "" {
+		template, err = taskQueue.ReadTemplate(payload.TemplatePlace)
+		if err != nil {
+			return nil, fmt.Errorf("template not found by place %v", payload.TemplatePlace)
+		}
+	}
+	if template == nil && payload.TemplateId != "" {
+		template, err = taskQueue.GetTemplate(payload.TemplateId)
+		if err != nil {
+			return nil, fmt.Errorf("template not found by id %v", payload.TemplateId)
+		}
+	}
+	if template == nil {
+		template = &taskQueue.Template{}
+	}
+
+	template, err = taskQueue.RenderTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	taskBase := template.GetTaskBase()
+
+	taskBase.Command = setValue(payload.Command, template.Command)
+	taskBase.Label = setValue(payload.Label, template.Label)
+	taskBase.Group = setValue(payload.Group, template.Group)
+	taskBase.IsPty = setValue(payload.IsPty, template.IsPty)
+	taskBase.IsOnlyCombined = setValue(payload.IsOnlyCombined, template.IsOnlyCombined)
+	taskBase.IsSingleInstance = setValue(payload.IsSingleInstance, template.IsSingleInstance)
+	taskBase.IsStartOnBoot = setValue(payload.IsStartOnBoot, template.IsStartOnBoot)
+	taskBase.IsWriteLogs = setValue(payload.IsWriteLogs, template.IsWriteLogs)
+	taskBase.TTL = setValue(payload.TTL, template.TTL)
+	taskBase.MaxRuntime = setValue(payload.MaxRuntime, template.MaxRuntime)
+	taskBase.StopSignal = setValue(payload.StopSignal, template.StopSignal)
+	taskBase.StopTimeout = setValue(payload.StopTimeout, template.StopTimeout)
+	taskBase.Retry = template.Retry
+	if payload.Retry != nil {
+		taskBase.Retry = payload.Retry
+	}
+	taskBase.Priority = setValue(payload.Priority, template.Priority)
+	taskBase.DependsOn = payload.DependsOn
+	taskBase.Env = payload.Env
+	taskBase.Restart = template.Restart
+	if payload.Restart != nil {
+		taskBase.Restart = payload.Restart
+	}
+	taskBase.Probe = template.Probe
+	if payload.Probe != nil {
+		taskBase.Probe = payload.Probe
+	}
+	taskBase.Resources = template.Resources
+	if payload.Resources != nil {
+		taskBase.Resources = payload.Resources
+	}
+	taskBase.RunAs = template.RunAs
+	if payload.RunAs != nil {
+		taskBase.RunAs = payload.RunAs
+	}
+	taskBase.WorkingDir = setValue(payload.WorkingDir, template.WorkingDir)
+	taskBase.Interpreter = template.Interpreter
+	if payload.Interpreter != nil {
+		taskBase.Interpreter = payload.Interpreter
+	}
+
+	variables := payload.Variables
+	if payload.Preset != "" {
+		preset, err := taskQueue.GetPreset(template.Place, payload.Preset)
+		if err != nil {
+			return nil, err
+		}
+		variables = make(map[string]string)
+		for key, value := range preset.Variables {
+			variables[key] = value
+		}
+		for key, value := range payload.Variables {
+			variables[key] = value
+		}
+	}
+
+	err = taskQueue.ApplyTemplateVariables(config, &taskBase, template, variables)
+	var variablesErr *taskQueue.VariablesError
+	if errors.As(err, &variablesErr) {
+		// the task base is rendered with invalid values for the preview
+		return &taskBase, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &taskBase, nil
+}
+
 func handleMemStorage(router *Router, memStorage *memstorage.MemStorage) {
 	router.Post("/api/memStorage/get", func(w http.ResponseWriter, r *http.Request) {
 		apiCall(w, func() (map[string]interface{}, error) {
@@ -589,7 +911,14 @@ func apiCall[T any](w http.ResponseWriter, action ActionAny[T]) {
 func writeApiResult(w http.ResponseWriter, result interface{}, err error) error {
 	var statusCode int
 	var body interface{}
-	if err != nil {
+	var variablesErr *taskQueue.VariablesError
+	if errors.As(err, &variablesErr) {
+		statusCode = 400
+		body = JsonFailResponse{
+			Error:  err.Error(),
+			Errors: variablesErr.Errors,
+		}
+	} else if err != nil {
 		statusCode = 500
 		body = JsonFailResponse{
 			Error: err.Error(),
@@ -617,7 +946,7 @@ func sendStatus(w http.ResponseWriter, statusCode int) {
 	}
 }
 
-func setValue[T int64 | string | bool](val *T, def T) T {
+func setValue[T int | int64 | string | bool](val *T, def T) T {
 	if val == nil {
 		return def
 	}
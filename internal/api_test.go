@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"goTaskQueue/internal/cfg"
+	"goTaskQueue/internal/taskQueue"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func postApi[T any](t *testing.T, router *Router, path string, payload any) (T, int) {
+	data, _ := json.Marshal(payload)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(data)))
+
+	var body struct {
+		Result T `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%v: %v %s", path, err, w.Body.Bytes())
+	}
+	return body.Result, w.Code
+}
+
+func TestPreviewMatchesAdd(t *testing.T) {
+	profilePath := cfg.PROFILE_PATH_CACHE
+	cfg.PROFILE_PATH_CACHE = t.TempDir()
+	taskQueue.HISTORY_CACHE = nil
+	taskQueue.FlushTemplateCache()
+	t.Cleanup(func() {
+		cfg.PROFILE_PATH_CACHE = profilePath
+		taskQueue.HISTORY_CACHE = nil
+		taskQueue.FlushTemplateCache()
+	})
+
+	template := taskQueue.Template{
+		Place:     "deploy",
+		Name:      "deploy",
+		Command:   "echo {name} \"{mode}\"\n",
+		Variables: []taskQueue.TemplateVariable{{Value: "name"}, {Value: "mode", Type: "enum", Options: []string{"fast", "slow"}}},
+	}
+	template.Label = "deploy {name}"
+	template.TTL = 60
+	if err := taskQueue.WriteTemplate(template, true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the queue is not loaded, the scheduler and the queue writer are not started
+	config := cfg.Config{}
+	queue := taskQueue.NewQueue(&config)
+	router := NewRouter()
+	handleAction(router, &config, queue, nil)
+
+	ttl := int64(5)
+	payload := AddTaskPayload{
+		TemplatePlace: "deploy",
+		Variables:     map[string]string{"name": "it's", "mode": "slow"},
+		TTL:           &ttl,
+		Env:           map[string]string{"A": "1"},
+	}
+	preview, code := postApi[taskQueue.TaskBase](t, router, "/api/preview", payload)
+	if code != 200 {
+		t.Fatalf("preview status %v", code)
+	}
+	task, code := postApi[taskQueue.TaskBase](t, router, "/api/add", payload)
+	if code != 200 {
+		t.Fatalf("add status %v", code)
+	}
+	if !reflect.DeepEqual(preview, task) {
+		t.Errorf("preview differs from the added task\n%+v\n%+v", preview, task)
+	}
+
+	// invalid variables do not abort the preview
+	payload.Variables["mode"] = "other"
+	invalid, code := postApi[taskQueue.TaskPreview](t, router, "/api/preview", payload)
+	if code != 200 || len(invalid.Errors) != 1 || invalid.Errors[0].Name != "mode" || invalid.Label != "deploy it's" {
+		t.Errorf("unexpected preview %v %+v", code, invalid)
+	}
+	if _, code := postApi[taskQueue.TaskBase](t, router, "/api/add", payload); code != 400 {
+		t.Errorf("expected add status 400, got %v", code)
+	}
+}
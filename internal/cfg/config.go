@@ -14,16 +14,29 @@ import (
 	"github.com/natefinch/atomic"
 )
 
+type TemplateRoot struct {
+	Place      string
+	Prefix     string
+	IsReadOnly bool
+}
+
 type Config struct {
-	Port          int
-	Address       string
-	Name          string
-	LogFolder     string
-	Run           []string
-	PtyRun        []string
-	PtyRunEnv     []string
-	RunEnv        []string
-	TemplateOrder []string
+	Port            int
+	Address         string
+	Name            string
+	LogFolder       string
+	Run             []string
+	PtyRun          []string
+	PtyRunEnv       []string
+	RunEnv          []string
+	TemplateOrder   []string
+	MaxParallel     int
+	GroupLimits     map[string]int
+	CgroupParent    string
+	UseShim         bool
+	ShutdownMode    string
+	ShutdownTimeout int64
+	TemplateRoots   []TemplateRoot
 }
 
 var APP_ID = "com.rndnm.gotaskqueue"
@@ -49,9 +62,11 @@ func (s *Config) GetLogsFolder() string {
 
 func getNewConfig() Config {
 	var config = Config{
-		Port:      80,
-		Name:      "TaskQueue",
-		LogFolder: "logs",
+		Port:         80,
+		Name:         "TaskQueue",
+		LogFolder:    "logs",
+		UseShim:      runtime.GOOS != "windows",
+		ShutdownMode: "detach",
 	}
 	if runtime.GOOS == "windows" {
 		config.Run = []string{"cmd", "/C"}
@@ -63,6 +78,8 @@ func getNewConfig() Config {
 	config.PtyRunEnv = []string{"TERM=xterm-256color", "COLORTERM=truecolor", "HOME=/root"}
 	config.RunEnv = []string{}
 	config.TemplateOrder = []string{}
+	config.GroupLimits = map[string]int{}
+	config.TemplateRoots = []TemplateRoot{}
 	return config
 }
 
@@ -109,6 +126,14 @@ func LoadConfig() Config {
 		config.TemplateOrder = newConfig.TemplateOrder
 	}
 
+	if config.GroupLimits == nil {
+		config.GroupLimits = newConfig.GroupLimits
+	}
+
+	if config.TemplateRoots == nil {
+		config.TemplateRoots = newConfig.TemplateRoots
+	}
+
 	if config.LogFolder == "" {
 		config.LogFolder = newConfig.LogFolder
 	}
@@ -1,5 +1,10 @@
 package logstore
 
+import (
+	"strconv"
+	"strings"
+)
+
 const ChunkSize = 10 * 1024 * 1024
 
 func getChunkIndex(off int64, cSize int) int {
@@ -17,3 +22,16 @@ func getChunksSize(chunks []*LogChunk, cSize int) (n int64) {
 func getAvailableSize(chunk *LogChunk, cSize int) int {
 	return cSize - chunk.Len
 }
+
+func getLastChunkIndex(chunks []*LogChunk) int {
+	l := len(chunks)
+	if l == 0 {
+		return 0
+	}
+	name := strings.TrimSuffix(chunks[l-1].Name, ".gz")
+	index, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	if err != nil {
+		return l
+	}
+	return index
+}
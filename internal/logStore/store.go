@@ -238,6 +238,22 @@ func (s *LogStore) Close() (err error) {
 	return s.Save()
 }
 
+func (s *LogStore) Remove() (err error) {
+	s.cwg.Wait()
+
+	for _, chunk := range s.GetChunks() {
+		if suberr := chunk.Remove(); suberr != nil && !os.IsNotExist(suberr) {
+			err = errors.Join(err, suberr)
+		}
+	}
+
+	filename := path.Join(s.place, s.Name+"-index")
+	if suberr := os.Remove(filename); suberr != nil && !os.IsNotExist(suberr) {
+		err = errors.Join(err, suberr)
+	}
+	return
+}
+
 func OpenLogStore(filename string) (ls *LogStore, err error) {
 	data, err := os.ReadFile(filename + "-index")
 	if err != nil {
@@ -258,6 +274,7 @@ func OpenLogStore(filename string) (ls *LogStore, err error) {
 			log.Println("Sync chunk len error", err)
 		}
 	}
+	store.chunkIndex = getLastChunkIndex(store.Chunks)
 
 	ls = &store
 	return
@@ -0,0 +1,199 @@
+//go:build linux
+
+package taskQueue
+
+import (
+	"errors"
+	"fmt"
+	"goTaskQueue/internal/cfg"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const CgroupCpuPeriod = 100000
+const CgroupRemoveMaxDelay = 10 * time.Second
+
+func (s *Task) setupCgroup(config *cfg.Config, process *exec.Cmd) (func(), error) {
+	if err := s.prepareCgroup(config); err != nil {
+		return nil, err
+	}
+
+	closeCgroup, err := attachCgroup(s.cgroup, process)
+	if err != nil {
+		s.removeCgroup()
+		return nil, err
+	}
+	return closeCgroup, nil
+}
+
+func (s *Task) prepareCgroup(config *cfg.Config) error {
+	if config.CgroupParent == "" {
+		if s.Resources != nil {
+			return errors.New("cgroup_parent_is_not_configured")
+		}
+		return nil
+	}
+
+	parent := config.CgroupParent
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	if err := enableCgroupControllers(parent); err != nil {
+		return err
+	}
+
+	place := filepath.Join(parent, fmt.Sprintf("task-%s-%d", s.Id, s.Attempt))
+	if err := os.Mkdir(place, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	s.cgroup = place
+
+	if err := s.Resources.write(place); err != nil {
+		s.removeCgroup()
+		return err
+	}
+	return nil
+}
+
+func attachCgroup(place string, process *exec.Cmd) (func(), error) {
+	if place == "" {
+		return func() {}, nil
+	}
+
+	fd, err := syscall.Open(place, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	process.SysProcAttr.UseCgroupFD = true
+	process.SysProcAttr.CgroupFD = fd
+
+	return func() {
+		syscall.Close(fd)
+	}, nil
+}
+
+func (s *TaskResources) write(place string) error {
+	if s == nil {
+		return nil
+	}
+
+	values := make(map[string]string)
+	if s.CpuQuota > 0 {
+		values["cpu.max"] = fmt.Sprintf("%d %d", int64(s.CpuQuota*CgroupCpuPeriod), CgroupCpuPeriod)
+	}
+	if s.MemoryMax > 0 {
+		values["memory.max"] = strconv.FormatInt(s.MemoryMax, 10)
+	}
+	if s.PidsMax > 0 {
+		values["pids.max"] = strconv.FormatInt(s.PidsMax, 10)
+	}
+	if s.IoWeight > 0 {
+		values["io.weight"] = "default " + strconv.Itoa(s.IoWeight)
+	}
+
+	for name, value := range values {
+		if err := os.WriteFile(filepath.Join(place, name), []byte(value), 0644); err != nil {
+			return fmt.Errorf("write %v error: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func enableCgroupControllers(parent string) error {
+	data, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+	if err != nil {
+		return err
+	}
+
+	var controllers []string
+	for _, name := range strings.Fields(string(data)) {
+		switch name {
+		case "cpu", "memory", "pids", "io":
+			controllers = append(controllers, "+"+name)
+		}
+	}
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	return os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(strings.Join(controllers, " ")), 0644)
+}
+
+func (s *Task) getCgroupPids() ([]int, error) {
+	data, err := os.ReadFile(filepath.Join(s.cgroup, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0)
+	for _, line := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(line); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+func (s *Task) signalCgroup(sig syscall.Signal) (bool, error) {
+	if s.cgroup == "" {
+		return false, nil
+	}
+
+	if sig == syscall.SIGKILL {
+		if err := os.WriteFile(filepath.Join(s.cgroup, "cgroup.kill"), []byte("1"), 0644); err == nil {
+			return true, nil
+		}
+	}
+
+	pids, err := s.getCgroupPids()
+	if err != nil {
+		return true, err
+	}
+	for _, pid := range pids {
+		suberr := syscall.Kill(pid, sig)
+		if !errors.Is(suberr, syscall.ESRCH) {
+			err = errors.Join(err, suberr)
+		}
+	}
+	return true, err
+}
+
+// removeCgroup does not kill processes left after the main process exit,
+// the cgroup is removed once they are gone
+func (s *Task) removeCgroup() {
+	if s.cgroup == "" {
+		return
+	}
+
+	place := s.cgroup
+	s.cgroup = ""
+
+	if err := os.Remove(place); err == nil || os.IsNotExist(err) {
+		return
+	}
+	go removeCgroupWhenEmpty(place)
+}
+
+func removeCgroupWhenEmpty(place string) {
+	delay := 100 * time.Millisecond
+	for {
+		time.Sleep(delay)
+
+		err := os.Remove(place)
+		if err == nil || os.IsNotExist(err) {
+			return
+		}
+		if !errors.Is(err, syscall.EBUSY) {
+			log.Println("Remove cgroup error", place, err)
+			return
+		}
+		delay = min(delay*2, CgroupRemoveMaxDelay)
+	}
+}
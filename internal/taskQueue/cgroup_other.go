@@ -0,0 +1,42 @@
+//go:build !linux
+
+package taskQueue
+
+import (
+	"errors"
+	"goTaskQueue/internal/cfg"
+	"os/exec"
+	"syscall"
+)
+
+func (s *Task) setupCgroup(config *cfg.Config, process *exec.Cmd) (func(), error) {
+	if err := s.prepareCgroup(config); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
+
+func (s *Task) prepareCgroup(config *cfg.Config) error {
+	if s.Resources != nil {
+		return errors.New("resource_limits_unsupported")
+	}
+	return nil
+}
+
+func attachCgroup(place string, process *exec.Cmd) (func(), error) {
+	if place != "" {
+		return nil, errors.New("cgroups_unsupported")
+	}
+	return func() {}, nil
+}
+
+func (s *Task) getCgroupPids() ([]int, error) {
+	return nil, errors.New("cgroups_unsupported")
+}
+
+func (s *Task) signalCgroup(sig syscall.Signal) (bool, error) {
+	return false, nil
+}
+
+func (s *Task) removeCgroup() {
+}
@@ -0,0 +1,167 @@
+package taskQueue
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+)
+
+type TaskCredential struct {
+	User   string   `json:"user"`
+	Group  string   `json:"group"`
+	Groups []string `json:"groups"`
+}
+
+// resolve returns the credential for the task process and HOME/USER env of the user,
+// user and groups may be names or numeric ids
+func (s *TaskCredential) resolve() (*syscall.Credential, []string, error) {
+	if s.User == "" && s.Group == "" && len(s.Groups) == 0 {
+		return nil, nil, nil
+	}
+
+	if os.Geteuid() != 0 {
+		return nil, nil, fmt.Errorf("run as user %q group %q requires the daemon to run as root", s.User, s.Group)
+	}
+
+	credential := &syscall.Credential{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+	env := make([]string, 0)
+
+	var u *user.User
+	if s.User != "" {
+		var err error
+		u, err = lookupUser(s.User)
+		if err != nil {
+			return nil, nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid uid %v", u.Uid)
+		}
+		credential.Uid = uint32(uid)
+		if gid, err := strconv.ParseUint(u.Gid, 10, 32); err == nil {
+			credential.Gid = uint32(gid)
+		}
+
+		if u.HomeDir != "" {
+			env = append(env, "HOME="+u.HomeDir)
+		}
+		if u.Username != "" {
+			env = append(env, "USER="+u.Username, "LOGNAME="+u.Username)
+		}
+	}
+
+	if s.Group != "" {
+		gid, err := lookupGroupId(s.Group)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Gid = gid
+	}
+
+	groups := s.Groups
+	if len(groups) == 0 && u != nil && u.Username != "" {
+		if ids, err := u.GroupIds(); err == nil {
+			groups = ids
+		}
+	}
+	credential.Groups = make([]uint32, 0, len(groups))
+	for _, group := range groups {
+		gid, err := lookupGroupId(group)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Groups = append(credential.Groups, gid)
+	}
+
+	return credential, env, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if u, err := user.Lookup(name); err == nil {
+		return u, nil
+	}
+	if _, err := strconv.ParseUint(name, 10, 32); err != nil {
+		return nil, fmt.Errorf("user not found %v", name)
+	}
+	if u, err := user.LookupId(name); err == nil {
+		return u, nil
+	}
+	// numeric id without passwd entry
+	return &user.User{Uid: name, Gid: name}, nil
+}
+
+func lookupGroupId(name string) (uint32, error) {
+	if g, err := user.LookupGroup(name); err == nil {
+		name = g.Gid
+	}
+	gid, err := strconv.ParseUint(name, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("group not found %v", name)
+	}
+	return uint32(gid), nil
+}
+
+func (s *Task) setCredential(process *exec.Cmd) error {
+	if s.RunAs == nil {
+		return nil
+	}
+
+	credential, _, err := s.RunAs.resolve()
+	if err != nil {
+		return err
+	}
+	if credential == nil {
+		return nil
+	}
+
+	if process.SysProcAttr == nil {
+		process.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	process.SysProcAttr.Credential = credential
+	return nil
+}
+
+// checkAccess returns an error when the credential can not enter every folder of the path,
+// the task process switches the user before it changes the working directory
+func checkAccess(place string, credential *syscall.Credential) error {
+	if place == "" || credential == nil || credential.Uid == 0 {
+		return nil
+	}
+
+	for place = filepath.Clean(place); ; place = filepath.Dir(place) {
+		info, err := os.Stat(place)
+		if err == nil && !hasPermission(info, credential, 1) {
+			return fmt.Errorf("user %d can not enter %v", credential.Uid, place)
+		}
+		// a missing folder may be created before the task starts
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if filepath.Dir(place) == place {
+			return nil
+		}
+	}
+}
+
+func hasPermission(info os.FileInfo, credential *syscall.Credential, perm os.FileMode) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	mode := info.Mode().Perm()
+	if stat.Uid == credential.Uid {
+		return mode&(perm<<6) != 0
+	}
+	if stat.Gid == credential.Gid || slices.Contains(credential.Groups, stat.Gid) {
+		return mode&(perm<<3) != 0
+	}
+	return mode&perm != 0
+}
@@ -0,0 +1,37 @@
+package taskQueue
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCheckAccess(t *testing.T) {
+	// folders of t.TempDir are closed for other users
+	root, err := os.MkdirTemp("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	os.Chmod(root, 0755)
+	open := filepath.Join(root, "open")
+	closed := filepath.Join(root, "closed")
+	os.Mkdir(open, 0711)
+	os.Mkdir(closed, 0700)
+
+	credential := &syscall.Credential{Uid: uint32(os.Getuid() + 12345), Gid: uint32(os.Getgid() + 12345)}
+
+	if err := checkAccess(filepath.Join(open, "missing"), credential); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := checkAccess(filepath.Join(closed, "work"), credential); err == nil {
+		t.Error("expected access error")
+	}
+
+	credential.Groups = []uint32{uint32(os.Getgid())}
+	os.Chmod(closed, 0710)
+	if err := checkAccess(closed, credential); err != nil {
+		t.Errorf("unexpected group error %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package taskQueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	loc := time.UTC
+	from := time.Date(2024, 1, 31, 10, 17, 30, 0, loc)
+
+	cases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 18, 0, 0, loc)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, loc)},
+		{"0 3 * * *", time.Date(2024, 2, 1, 3, 0, 0, 0, loc)},
+		{"30 9 * * mon-fri", time.Date(2024, 2, 1, 9, 30, 0, 0, loc)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, loc)},
+		{"0 12 1 * 0", time.Date(2024, 2, 1, 12, 0, 0, 0, loc)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, loc)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, loc)},
+		{"5,45 10-11 * * *", time.Date(2024, 1, 31, 10, 45, 0, 0, loc)},
+	}
+
+	for _, c := range cases {
+		expr, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.expr, err)
+		}
+		if next := expr.Next(from); !next.Equal(c.next) {
+			t.Errorf("%q: expected %v, got %v", c.expr, c.next, next)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package taskQueue
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	DependencyWait    = 0
+	DependencyDone    = 1
+	DependencyFailure = 2
+)
+
+type TaskGraphNode struct {
+	Id        string   `json:"id"`
+	Label     string   `json:"label"`
+	State     string   `json:"state"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type TaskGraph struct {
+	Nodes []TaskGraphNode `json:"nodes"`
+}
+
+func (s *Task) isSucceeded() bool {
+	return s.IsFinished && !s.IsError && !s.IsCanceled && !s.IsTimeout && !s.IsSkipped
+}
+
+func (s *Task) isReady() bool {
+	return s.Probe != nil && s.IsStarted && !s.IsFinished && s.Health == HEALTH_READY
+}
+
+func (s *Queue) checkDependencies(taskBase TaskBase) error {
+	for _, id := range taskBase.DependsOn {
+		if _, err := s.Get(id); err != nil {
+			return fmt.Errorf("dependency not found %v", id)
+		}
+	}
+	return nil
+}
+
+func (s *Queue) getDependencyState(task *Task) (int, string) {
+	state := DependencyDone
+	for _, id := range task.DependsOn {
+		dep, err := s.Get(id)
+		if err != nil {
+			if slices.Contains(task.DoneDependencies, id) {
+				continue
+			}
+			return DependencyFailure, id
+		}
+		if dep.isSucceeded() || dep.isReady() {
+			continue
+		}
+		if dep.IsFinished {
+			return DependencyFailure, id
+		}
+		state = DependencyWait
+	}
+	return state, ""
+}
+
+// markDoneDependency keeps pending dependents satisfied when a succeeded task is removed
+func (s *Queue) markDoneDependency(task *Task) {
+	if !task.isSucceeded() {
+		return
+	}
+
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	for _, t := range s.getTasks() {
+		if t.IsFinished || !slices.Contains(t.DependsOn, task.Id) {
+			continue
+		}
+		t.DoneDependencies = append(t.DoneDependencies, task.Id)
+	}
+}
+
+func (s *Task) skip(dependencyId string) {
+	s.IsPending = false
+	s.IsSkipped = true
+	s.IsFinished = true
+	s.Error = fmt.Sprintf("dependency failed %v", dependencyId)
+	s.syncStatusAndSave()
+
+	go s.pushChanges(0)
+}
+
+func (s *Queue) GetGraph(id string) (*TaskGraph, error) {
+	// dependencies and task states are changed by the scheduler under smu
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	task, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	dependents := make(map[string][]string)
+	for _, t := range s.getTasks() {
+		for _, depId := range t.DependsOn {
+			dependents[depId] = append(dependents[depId], t.Id)
+		}
+	}
+
+	if len(task.DependsOn) == 0 && len(dependents[task.Id]) == 0 {
+		return nil, nil
+	}
+
+	graph := &TaskGraph{
+		Nodes: make([]TaskGraphNode, 0),
+	}
+	visited := make(map[string]bool)
+	var visit func(id string)
+	visit = func(id string) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+
+		t, err := s.Get(id)
+		if err != nil {
+			graph.Nodes = append(graph.Nodes, TaskGraphNode{Id: id, DependsOn: make([]string, 0)})
+			return
+		}
+
+		node := TaskGraphNode{
+			Id:        t.Id,
+			Label:     t.Label,
+			State:     t.State,
+			DependsOn: make([]string, 0),
+		}
+		node.DependsOn = append(node.DependsOn, t.DependsOn...)
+		graph.Nodes = append(graph.Nodes, node)
+
+		for _, depId := range t.DependsOn {
+			visit(depId)
+		}
+		for _, depId := range dependents[id] {
+			visit(depId)
+		}
+	}
+	visit(task.Id)
+
+	return graph, nil
+}
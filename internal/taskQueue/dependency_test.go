@@ -0,0 +1,26 @@
+package taskQueue
+
+import "testing"
+
+func TestDependencyState(t *testing.T) {
+	done := &Task{Id: "done", IsFinished: true}
+	failed := &Task{Id: "failed", IsFinished: true, IsError: true}
+	running := &Task{Id: "running", IsStarted: true}
+	queue := &Queue{idTask: map[string]*Task{"done": done, "failed": failed, "running": running}}
+
+	cases := []struct {
+		task     *Task
+		expected int
+	}{
+		{&Task{TaskBase: TaskBase{DependsOn: []string{"done"}}}, DependencyDone},
+		{&Task{TaskBase: TaskBase{DependsOn: []string{"done", "running"}}}, DependencyWait},
+		{&Task{TaskBase: TaskBase{DependsOn: []string{"failed"}}}, DependencyFailure},
+		{&Task{TaskBase: TaskBase{DependsOn: []string{"removed"}}}, DependencyFailure},
+		{&Task{TaskBase: TaskBase{DependsOn: []string{"removed"}, DoneDependencies: []string{"removed"}}}, DependencyDone},
+	}
+	for i, c := range cases {
+		if state, _ := queue.getDependencyState(c.task); state != c.expected {
+			t.Errorf("case %d: expected %d, got %d", i, c.expected, state)
+		}
+	}
+}
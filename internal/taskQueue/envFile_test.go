@@ -0,0 +1,28 @@
+package taskQueue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	data := []byte("# comment\n\nFOO=bar\nexport PATH_X = /opt/x \nQUOTED=\"a b\"\nSINGLE='c'\nEMPTY=\nEQ=a=b\n")
+
+	env, err := parseEnvFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"FOO=bar", "PATH_X=/opt/x", "QUOTED=a b", "SINGLE=c", "EMPTY=", "EQ=a=b"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("expected %q, got %q", expected, env)
+	}
+}
+
+func TestParseEnvFileInvalid(t *testing.T) {
+	for _, data := range []string{"FOO", "=bar", "A B=c"} {
+		if _, err := parseEnvFile([]byte(data)); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
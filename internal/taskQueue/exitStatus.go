@@ -0,0 +1,37 @@
+package taskQueue
+
+import (
+	"os"
+)
+
+type TaskExitStatus struct {
+	ExitCode   int     `json:"exitCode"`
+	Signal     int     `json:"signal"`
+	SignalName string  `json:"signalName"`
+	IsCoreDump bool    `json:"isCoreDump"`
+	UserTime   float64 `json:"userTime"`
+	SystemTime float64 `json:"systemTime"`
+	MaxRss     int64   `json:"maxRss"`
+}
+
+func NewExitStatus(state *os.ProcessState) *TaskExitStatus {
+	if state == nil {
+		return nil
+	}
+
+	status := &TaskExitStatus{
+		ExitCode:   state.ExitCode(),
+		UserTime:   state.UserTime().Seconds(),
+		SystemTime: state.SystemTime().Seconds(),
+	}
+	fillExitStatus(status, state)
+
+	return status
+}
+
+func getExitCode(status *TaskExitStatus) int {
+	if status == nil {
+		return -1
+	}
+	return status.ExitCode
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package taskQueue
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func fillExitStatus(status *TaskExitStatus, state *os.ProcessState) {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Signal = int(ws.Signal())
+		status.SignalName = ws.Signal().String()
+		status.IsCoreDump = ws.CoreDump()
+	}
+
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		status.MaxRss = int64(rusage.Maxrss)
+		if runtime.GOOS == "linux" {
+			status.MaxRss *= 1024
+		}
+	}
+}
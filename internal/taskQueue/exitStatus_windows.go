@@ -0,0 +1,10 @@
+//go:build windows
+
+package taskQueue
+
+import (
+	"os"
+)
+
+func fillExitStatus(status *TaskExitStatus, state *os.ProcessState) {
+}
@@ -0,0 +1,123 @@
+package taskQueue
+
+import (
+	"goTaskQueue/internal/cfg"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type TaskInterpreter struct {
+	Command []string `json:"command"`
+	Ext     string   `json:"ext"`
+}
+
+var INTERPRETER_EXT = map[string]string{
+	"sh":      ".sh",
+	"bash":    ".sh",
+	"zsh":     ".sh",
+	"python":  ".py",
+	"python3": ".py",
+	"node":    ".js",
+	"deno":    ".ts",
+	"ruby":    ".rb",
+	"perl":    ".pl",
+	"php":     ".php",
+	"pwsh":    ".ps1",
+}
+
+func (s *TaskInterpreter) isEmpty() bool {
+	return s == nil || len(s.Command) == 0
+}
+
+// isShell reports whether the command is run by a shell and shell quoting applies
+func (s *TaskInterpreter) isShell() bool {
+	return s.isEmpty() || s.getExt() == ".sh"
+}
+
+// getLanguage returns the script extension of the interpreter, all shells share one
+func (s *TaskInterpreter) getLanguage() string {
+	if s.isShell() {
+		return ".sh"
+	}
+	if ext := s.getExt(); ext != "" {
+		return ext
+	}
+	return filepath.Base(s.Command[0])
+}
+
+func (s *TaskInterpreter) getExt() string {
+	if s.Ext != "" {
+		if !strings.HasPrefix(s.Ext, ".") {
+			return "." + s.Ext
+		}
+		return s.Ext
+	}
+	name := filepath.Base(s.Command[0])
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return INTERPRETER_EXT[name]
+}
+
+func getCommandName(interpreter *TaskInterpreter) string {
+	if interpreter.isEmpty() {
+		return COMMAND_NAME
+	}
+	return "command" + interpreter.getExt()
+}
+
+// getCommand returns argv of the task process, with an interpreter the command
+// is written to a script file instead of passing it as an argument
+func (s *Task) getCommand(runAs []string) ([]string, error) {
+	if s.Interpreter.isEmpty() {
+		return append(append([]string{}, runAs...), s.Command), nil
+	}
+
+	script, err := s.writeScript()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, s.Interpreter.Command...), script), nil
+}
+
+func (s *Task) writeScript() (string, error) {
+	place := getScriptsPath()
+	if err := os.MkdirAll(place, 0711); err != nil {
+		return "", err
+	}
+
+	filename := s.getScriptFilename()
+	if err := os.WriteFile(filename, []byte(s.Command), 0700); err != nil {
+		return "", err
+	}
+
+	if s.RunAs != nil {
+		credential, _, err := s.RunAs.resolve()
+		if err == nil && credential != nil {
+			err = os.Chown(filename, int(credential.Uid), int(credential.Gid))
+		}
+		if err != nil {
+			os.Remove(filename)
+			return "", err
+		}
+	}
+
+	return filename, nil
+}
+
+func (s *Task) removeScript() {
+	if s.Interpreter.isEmpty() {
+		return
+	}
+	if err := os.Remove(s.getScriptFilename()); err != nil && !os.IsNotExist(err) {
+		log.Println("Remove script error", s.Id, err)
+	}
+}
+
+func (s *Task) getScriptFilename() string {
+	return filepath.Join(getScriptsPath(), s.Id+s.Interpreter.getExt())
+}
+
+func getScriptsPath() string {
+	return filepath.Join(cfg.GetProfilePath(), "scripts")
+}
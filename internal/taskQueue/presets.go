@@ -0,0 +1,151 @@
+package taskQueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"goTaskQueue/internal/utils"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/natefinch/atomic"
+)
+
+const PRESETS_NAME = "presets.json"
+
+type TemplatePreset struct {
+	Name      string            `json:"name"`
+	Variables map[string]string `json:"variables"`
+}
+
+var presetsMu sync.Mutex
+
+func ReadPresets(relPlace string) ([]TemplatePreset, error) {
+	place, err := getTemplateFolder(relPlace)
+	if err != nil {
+		return nil, err
+	}
+
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	return readPresets(place)
+}
+
+func GetPreset(relPlace string, name string) (*TemplatePreset, error) {
+	presets, err := ReadPresets(relPlace)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, preset := range presets {
+		if preset.Name == name {
+			return &preset, nil
+		}
+	}
+	return nil, errors.New("preset_not_found")
+}
+
+// SetPreset adds the preset or replaces the preset with the same name
+func SetPreset(relPlace string, preset TemplatePreset) error {
+	if preset.Name == "" {
+		return errors.New("preset_name_is_empty")
+	}
+
+	place, err := getTemplateFolder(relPlace)
+	if err != nil {
+		return err
+	}
+	if isReadOnlyPlace(place) {
+		return errors.New("template_root_is_read_only")
+	}
+
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	presets, err := readPresets(place)
+	if err != nil {
+		return err
+	}
+
+	isFound := false
+	for i := range presets {
+		if presets[i].Name == preset.Name {
+			presets[i] = preset
+			isFound = true
+			break
+		}
+	}
+	if !isFound {
+		presets = append(presets, preset)
+	}
+
+	return writePresets(place, presets)
+}
+
+func RemovePreset(relPlace string, name string) error {
+	place, err := getTemplateFolder(relPlace)
+	if err != nil {
+		return err
+	}
+	if isReadOnlyPlace(place) {
+		return errors.New("template_root_is_read_only")
+	}
+
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+
+	presets, err := readPresets(place)
+	if err != nil {
+		return err
+	}
+
+	result := make([]TemplatePreset, 0, len(presets))
+	for _, preset := range presets {
+		if preset.Name != name {
+			result = append(result, preset)
+		}
+	}
+	if len(result) == len(presets) {
+		return errors.New("preset_not_found")
+	}
+
+	return writePresets(place, result)
+}
+
+func getTemplateFolder(relPlace string) (string, error) {
+	place, err := getPlace(relPlace)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(filepath.Join(place, TEMPALTE_NAME)); err != nil {
+		return "", err
+	}
+	return place, nil
+}
+
+func readPresets(place string) ([]TemplatePreset, error) {
+	data, err := os.ReadFile(filepath.Join(place, PRESETS_NAME))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]TemplatePreset, 0), nil
+		}
+		return nil, err
+	}
+
+	presets, err := utils.ParseJson[[]TemplatePreset](bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return *presets, nil
+}
+
+func writePresets(place string, presets []TemplatePreset) error {
+	data, err := json.MarshalIndent(presets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomic.WriteFile(filepath.Join(place, PRESETS_NAME), bytes.NewReader(data))
+}
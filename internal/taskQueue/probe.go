@@ -0,0 +1,159 @@
+package taskQueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goTaskQueue/internal/cfg"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+const PROBE_TCP = "tcp"
+const PROBE_HTTP = "http"
+const PROBE_COMMAND = "command"
+
+const HEALTH_READY = "READY"
+const HEALTH_UNHEALTHY = "UNHEALTHY"
+
+const DefaultProbeInterval = 5
+const DefaultProbeTimeout = 3
+const DefaultProbeFailureThreshold = 3
+
+type TaskProbe struct {
+	Type             string `json:"type"`
+	Address          string `json:"address"`
+	Url              string `json:"url"`
+	Command          string `json:"command"`
+	InitialDelay     int64  `json:"initialDelay"`
+	Interval         int64  `json:"interval"`
+	Timeout          int64  `json:"timeout"`
+	FailureThreshold int    `json:"failureThreshold"`
+	IsRestart        bool   `json:"isRestart"`
+}
+
+func (s *TaskProbe) getInterval() time.Duration {
+	return getSeconds(s.Interval, DefaultProbeInterval)
+}
+
+func (s *TaskProbe) getTimeout() time.Duration {
+	return getSeconds(s.Timeout, DefaultProbeTimeout)
+}
+
+func (s *TaskProbe) getFailureThreshold() int {
+	if s.FailureThreshold > 0 {
+		return s.FailureThreshold
+	}
+	return DefaultProbeFailureThreshold
+}
+
+func (s *Task) watchProbe(config *cfg.Config) {
+	probe := s.Probe
+	if probe == nil {
+		return
+	}
+
+	done := s.done
+	go func() {
+		delay := time.Duration(probe.InitialDelay) * time.Second
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(delay):
+			}
+			delay = probe.getInterval()
+
+			err := s.runProbe(config, probe)
+
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if s.onProbe(err) {
+				s.isRestartProbe = true
+				s.terminate(done)
+				return
+			}
+		}
+	}()
+}
+
+func (s *Task) onProbe(err error) (isRestart bool) {
+	prevHealth := s.Health
+
+	if err == nil {
+		s.ProbeFailures = 0
+		s.Health = HEALTH_READY
+	} else {
+		s.ProbeFailures++
+		if s.ProbeFailures >= s.Probe.getFailureThreshold() {
+			if s.Health != HEALTH_UNHEALTHY {
+				log.Println("Task is unhealthy", s.Id, err)
+			}
+			s.Health = HEALTH_UNHEALTHY
+			isRestart = s.Probe.IsRestart
+		}
+	}
+
+	if prevHealth != s.Health {
+		s.syncStatusAndSave()
+		go s.pushChanges(1)
+		go s.queue.Schedule()
+	}
+	return
+}
+
+func (s *Task) runProbe(config *cfg.Config, probe *TaskProbe) error {
+	timeout := probe.getTimeout()
+
+	switch probe.Type {
+	case PROBE_TCP:
+		conn, err := net.DialTimeout("tcp", probe.Address, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case PROBE_HTTP:
+		client := http.Client{Timeout: timeout}
+		resp, err := client.Get(probe.Url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("probe status code %v", resp.StatusCode)
+		}
+		return nil
+	case PROBE_COMMAND:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		runAs := config.Run
+		args := append(append([]string{}, runAs[1:]...), probe.Command)
+		process := exec.CommandContext(ctx, runAs[0], args...)
+		env, err := s.getEnvVariables(config)
+		if err != nil {
+			return err
+		}
+		process.Env = env
+		process.Dir = s.getWorkingDir()
+		if err := s.setCredential(process); err != nil {
+			return err
+		}
+		return process.Run()
+	}
+	return errors.New("unknown_probe_type")
+}
+
+func getSeconds(value int64, def int64) time.Duration {
+	if value <= 0 {
+		value = def
+	}
+	return time.Duration(value) * time.Second
+}
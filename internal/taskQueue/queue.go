@@ -8,6 +8,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,36 +19,96 @@ import (
 )
 
 type Queue struct {
-	Tasks  []*Task `json:"tasks"`
-	idTask map[string]*Task
-	ch     chan int
-	mu     sync.Mutex
+	Tasks      []*Task `json:"tasks"`
+	IsDraining bool    `json:"isDraining"`
+	idTask     map[string]*Task
+	ch         chan int
+	mu         sync.Mutex
+	smu        sync.Mutex
+	config     *cfg.Config
+	schedules  *ScheduleStore
+	isShutdown bool
 }
 
 func (s *Queue) GetAll(config *cfg.Config) []*Task {
-	return s.Tasks
+	return s.getTasks()
+}
+
+// getTasks returns a copy of the task list, Tasks and idTask are changed only under mu
+func (s *Queue) getTasks() []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.Tasks)
 }
 
 func (s *Queue) Get(id string) (*Task, error) {
+	s.mu.Lock()
 	task, ok := s.idTask[id]
+	s.mu.Unlock()
 	if !ok {
 		return nil, errors.New("Task not found")
 	}
 	return task, nil
 }
 
-func (s *Queue) Add(config *cfg.Config, taskBase TaskBase) *Task {
-	id := s.getId()
-	task := NewTask(id, taskBase)
+func (s *Queue) Add(config *cfg.Config, taskBase TaskBase) (*Task, error) {
+	if err := s.validate(taskBase); err != nil {
+		return nil, err
+	}
 
 	s.mu.Lock()
+	task := NewTask(s.getId(), taskBase)
 	s.Tasks = append(s.Tasks, task)
 	s.idTask[task.Id] = task
 	s.mu.Unlock()
 
 	task.Init(config, s)
 	s.Save()
-	return task
+	return task, nil
+}
+
+// Preview returns the task base with the environment and the working directory
+// the task would get, without adding the task
+func (s *Queue) Preview(config *cfg.Config, taskBase TaskBase) (*TaskPreview, error) {
+	if err := s.validate(taskBase); err != nil {
+		return nil, err
+	}
+
+	task := &Task{TaskBase: taskBase}
+	env, err := task.getEnvVariables(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskPreview{
+		TaskBase:    taskBase,
+		EnvList:     env,
+		FullWorkDir: task.getWorkingDir(),
+	}, nil
+}
+
+func (s *Queue) validate(taskBase TaskBase) error {
+	if err := s.checkDependencies(taskBase); err != nil {
+		return err
+	}
+
+	if taskBase.RunAs != nil {
+		credential, _, err := taskBase.RunAs.resolve()
+		if err != nil {
+			return err
+		}
+
+		task := &Task{TaskBase: taskBase}
+		if err := checkAccess(task.getWorkingDir(), credential); err != nil {
+			return err
+		}
+		if !taskBase.Interpreter.isEmpty() {
+			if err := checkAccess(getScriptsPath(), credential); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (s *Queue) Clone(config *cfg.Config, id string) (*Task, error) {
@@ -54,9 +117,76 @@ func (s *Queue) Clone(config *cfg.Config, id string) (*Task, error) {
 		return nil, err
 	}
 
-	task := s.Add(config, origTask.TaskBase)
+	return s.Add(config, origTask.TaskBase)
+}
 
-	return task, nil
+// Rerun renders the current template of the task again with the same variables,
+// changed by overrides, other fields of the task are kept
+func (s *Queue) Rerun(config *cfg.Config, id string, overrides map[string]string) (*Task, error) {
+	origTask, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := origTask.getTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	template, err = RenderTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(origTask.Variables)+len(overrides))
+	for key, value := range origTask.Variables {
+		values[key] = value
+	}
+	for key, value := range overrides {
+		values[key] = value
+	}
+
+	rendered := template.GetTaskBase()
+	if err := ApplyTemplateVariables(config, &rendered, template, values); err != nil {
+		return nil, err
+	}
+
+	taskBase := origTask.TaskBase
+	// the command is run by the interpreter of the same template
+	taskBase.Command = rendered.Command
+	taskBase.Interpreter = rendered.Interpreter
+	taskBase.TemplatePlace = rendered.TemplatePlace
+	taskBase.TemplateId = rendered.TemplateId
+	taskBase.TemplateRevision = rendered.TemplateRevision
+	taskBase.TemplateChain = rendered.TemplateChain
+	taskBase.Variables = rendered.Variables
+	taskBase.DependsOn = nil
+	taskBase.DoneDependencies = nil
+
+	env := make(map[string]string, len(origTask.Env)+len(rendered.Env))
+	for key, value := range origTask.Env {
+		if !strings.HasPrefix(key, VariableEnvPrefix) {
+			env[key] = value
+		}
+	}
+	for key, value := range rendered.Env {
+		env[key] = value
+	}
+	taskBase.Env = nil
+	if len(env) > 0 {
+		taskBase.Env = env
+	}
+
+	// the label and the working dir given when the task was added are kept,
+	// the template ones are rendered with new values
+	if origTask.Label == replaceVariables(template.Label, template.Variables, origTask.Variables) {
+		taskBase.Label = rendered.Label
+	}
+	if origTask.WorkingDir == replaceVariables(template.WorkingDir, template.Variables, origTask.Variables) {
+		taskBase.WorkingDir = rendered.WorkingDir
+	}
+
+	return s.Add(config, taskBase)
 }
 
 func (s *Queue) Del(config *cfg.Config, id string) error {
@@ -68,21 +198,22 @@ func (s *Queue) Del(config *cfg.Config, id string) error {
 		return errors.New("Task is not finished")
 	}
 
-	var index int
-	for i, t := range s.Tasks {
-		if t.Id == id {
-			index = i
-			break
-		}
-	}
+	s.markDoneDependency(task)
 
 	s.mu.Lock()
-	s.Tasks = append(s.Tasks[:index], s.Tasks[index+1:]...)
+	index := slices.Index(s.Tasks, task)
+	if index == -1 {
+		s.mu.Unlock()
+		return errors.New("Task not found")
+	}
+	s.Tasks = slices.Delete(s.Tasks, index, index+1)
 	delete(s.idTask, task.Id)
 	s.mu.Unlock()
 
 	s.Save()
 
+	go s.Schedule()
+
 	if task.IsWriteLogs {
 		err := CleanTaskLogs(config, task.Id)
 		if err != nil {
@@ -94,14 +225,133 @@ func (s *Queue) Del(config *cfg.Config, id string) error {
 }
 
 func (s *Queue) HasInstance(templatePlace string) bool {
-	for _, t := range s.Tasks {
-		if t.IsStarted && !t.IsFinished && t.TemplatePlace == templatePlace {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	for _, t := range s.getTasks() {
+		if (t.IsPending || t.IsStarted && !t.IsFinished) && t.TemplatePlace == templatePlace {
 			return true
 		}
 	}
 	return false
 }
 
+func (s *Queue) Enqueue(task *Task) error {
+	s.smu.Lock()
+	task.IsPending = true
+	task.PendingAt = time.Now()
+	s.smu.Unlock()
+
+	task.syncStatusAndSave()
+
+	return s.schedule(task)
+}
+
+func (s *Queue) Dequeue(task *Task) error {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	if !task.IsPending {
+		return errors.New("task_is_not_pending")
+	}
+
+	task.IsPending = false
+	task.IsCanceled = true
+	task.IsFinished = true
+	task.syncStatusAndSave()
+
+	go task.pushChanges(0)
+
+	// dependents of the canceled task are skipped
+	go s.Schedule()
+
+	return nil
+}
+
+func (s *Queue) Schedule() {
+	if err := s.schedule(nil); err != nil {
+		log.Println("Schedule tasks error", err)
+	}
+}
+
+func (s *Queue) schedule(target *Task) (err error) {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	total, groups := s.getRunningCount()
+	isSkipped := false
+
+	for _, task := range s.getPendingTasks() {
+		if state, depId := s.getDependencyState(task); state == DependencyFailure {
+			task.skip(depId)
+			isSkipped = true
+			continue
+		} else if state == DependencyWait {
+			continue
+		}
+		if s.IsDraining || s.isShutdown {
+			continue
+		}
+		if s.config.MaxParallel > 0 && total >= s.config.MaxParallel {
+			break
+		}
+		if limit, ok := s.config.GroupLimits[task.Group]; ok && limit > 0 && groups[task.Group] >= limit {
+			continue
+		}
+
+		task.IsPending = false
+		if runErr := task.start(s.config); runErr != nil {
+			if task == target {
+				err = runErr
+			} else {
+				log.Println("Start pending task error", task.Id, runErr)
+				task.IsFinished = true
+				task.IsError = true
+				task.Error = runErr.Error()
+			}
+			task.syncStatusAndSave()
+			continue
+		}
+
+		total++
+		groups[task.Group]++
+	}
+
+	if isSkipped {
+		go s.Schedule()
+	}
+
+	return
+}
+
+func (s *Queue) getRunningCount() (total int, groups map[string]int) {
+	groups = make(map[string]int)
+	for _, task := range s.getTasks() {
+		if task.IsStarted && !task.IsFinished {
+			total++
+			groups[task.Group]++
+		}
+	}
+	return
+}
+
+func (s *Queue) getPendingTasks() []*Task {
+	tasks := make([]*Task, 0)
+	for _, task := range s.getTasks() {
+		if task.IsPending {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Priority != tasks[j].Priority {
+			return tasks[i].Priority > tasks[j].Priority
+		}
+		return tasks[i].PendingAt.Before(tasks[j].PendingAt)
+	})
+	return tasks
+}
+
+// getId is called under mu
 func (s *Queue) getId() string {
 	var id string
 	for {
@@ -140,7 +390,7 @@ func (s *Queue) RunOnBoot(config *cfg.Config) {
 	unic := map[string]bool{}
 	ids := make([]string, 0)
 
-	for _, task := range s.Tasks {
+	for _, task := range s.getTasks() {
 		if task.IsStartOnBoot && !unic[task.TemplatePlace] {
 			unic[task.TemplatePlace] = true
 			ids = append(ids, task.Id)
@@ -161,10 +411,12 @@ func (s *Queue) RunOnBoot(config *cfg.Config) {
 func (s *Queue) Cleanup(config *cfg.Config) {
 	var delIds []string
 
-	for _, task := range s.Tasks {
+	for _, task := range s.getTasks() {
 		if !task.IsStarted ||
 			!task.IsFinished ||
 			task.IsCanceled ||
+			task.IsSkipped ||
+			task.IsTimeout ||
 			task.IsError ||
 			task.ExpiresAt.IsZero() {
 			continue
@@ -183,7 +435,7 @@ func (s *Queue) Cleanup(config *cfg.Config) {
 }
 
 func LoadQueue(config *cfg.Config) *Queue {
-	queue := NewQueue()
+	queue := NewQueue(config)
 
 	path := getQueuePath()
 	data, err := os.ReadFile(path)
@@ -199,6 +451,8 @@ func LoadQueue(config *cfg.Config) *Queue {
 		task.Init(config, queue)
 	}
 
+	go queue.Schedule()
+
 	go func() {
 		for {
 			<-queue.ch
@@ -216,11 +470,14 @@ func getQueuePath() string {
 	return filepath.Join(cfg.GetProfilePath(), "queue.json")
 }
 
-func NewQueue() *Queue {
+// NewQueue returns an empty queue, unlike LoadQueue it doesn't start the scheduler and the queue writer
+func NewQueue(config *cfg.Config) *Queue {
 	queue := &Queue{
-		Tasks:  make([]*Task, 0),
-		idTask: make(map[string]*Task),
-		ch:     make(chan int, 1),
+		Tasks:     make([]*Task, 0),
+		idTask:    make(map[string]*Task),
+		ch:        make(chan int, 1),
+		config:    config,
+		schedules: loadScheduleStore(),
 	}
 	return queue
 }
@@ -0,0 +1,8 @@
+package taskQueue
+
+type TaskResources struct {
+	CpuQuota  float64 `json:"cpuQuota"`
+	MemoryMax int64   `json:"memoryMax"`
+	PidsMax   int64   `json:"pidsMax"`
+	IoWeight  int     `json:"ioWeight"`
+}
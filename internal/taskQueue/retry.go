@@ -0,0 +1,199 @@
+package taskQueue
+
+import (
+	"errors"
+	"goTaskQueue/internal/cfg"
+	"log"
+	"slices"
+	"time"
+)
+
+const BACKOFF_FIXED = "fixed"
+const BACKOFF_EXPONENTIAL = "exponential"
+
+const RESTART_NEVER = "never"
+const RESTART_ON_FAILURE = "on-failure"
+const RESTART_ALWAYS = "always"
+
+const DefaultRetryDelay = 5
+
+// MaxTaskAttempts limits the kept attempts history, services restarted forever drop the oldest attempts
+const MaxTaskAttempts = 100
+
+type RetryPolicy struct {
+	MaxAttempts int    `json:"maxAttempts"`
+	Backoff     string `json:"backoff"`
+	Delay       int64  `json:"delay"`
+	MaxDelay    int64  `json:"maxDelay"`
+	ExitCodes   []int  `json:"exitCodes"`
+}
+
+type RestartPolicy struct {
+	Policy   string `json:"policy"`
+	Limit    int    `json:"limit"`
+	Interval int64  `json:"interval"`
+	Backoff  string `json:"backoff"`
+	Delay    int64  `json:"delay"`
+	MaxDelay int64  `json:"maxDelay"`
+}
+
+type TaskAttempt struct {
+	Attempt    int             `json:"attempt"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	IsError    bool            `json:"isError"`
+	IsTimeout  bool            `json:"isTimeout"`
+	Error      string          `json:"error"`
+	ExitStatus *TaskExitStatus `json:"exitStatus"`
+	LogStart   int64           `json:"logStart"`
+	LogEnd     int64           `json:"logEnd"`
+}
+
+func (s *RetryPolicy) isRetryable(exitCode int) bool {
+	if len(s.ExitCodes) == 0 {
+		return true
+	}
+	return slices.Contains(s.ExitCodes, exitCode)
+}
+
+func (s *RetryPolicy) getDelay(attempt int) time.Duration {
+	return getBackoffDelay(s.Backoff, s.Delay, s.MaxDelay, attempt)
+}
+
+func (s *RestartPolicy) isRestartable(err error) bool {
+	switch s.Policy {
+	case RESTART_ALWAYS:
+		return true
+	case RESTART_ON_FAILURE:
+		return err != nil
+	}
+	return false
+}
+
+func getBackoffDelay(backoff string, delay int64, maxDelay int64, attempt int) time.Duration {
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	if backoff == BACKOFF_EXPONENTIAL {
+		for i := 1; i < attempt; i++ {
+			delay *= 2
+			if maxDelay > 0 && delay >= maxDelay {
+				break
+			}
+		}
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return time.Duration(delay) * time.Second
+}
+
+func (s *Task) addAttempt(err error) {
+	attempt := TaskAttempt{
+		Attempt:    s.Attempt,
+		StartedAt:  s.attemptStart,
+		FinishedAt: s.FinishedAt,
+		IsTimeout:  s.IsTimeout,
+		ExitStatus: s.ExitStatus,
+		LogStart:   s.attemptLog,
+		LogEnd:     s.getCombinedLen(),
+	}
+	if err != nil {
+		attempt.IsError = true
+		attempt.Error = err.Error()
+	}
+	s.Attempts = append(s.Attempts, attempt)
+	if len(s.Attempts) > MaxTaskAttempts {
+		s.Attempts = append([]TaskAttempt{}, s.Attempts[len(s.Attempts)-MaxTaskAttempts:]...)
+	}
+}
+
+func (s *Task) getRetryDelay(err error) (time.Duration, bool) {
+	if err == nil || s.isStopped || s.Retry == nil {
+		return 0, false
+	}
+	if s.Attempt >= s.Retry.MaxAttempts {
+		return 0, false
+	}
+	if !s.Retry.isRetryable(getExitCode(s.ExitStatus)) {
+		return 0, false
+	}
+	return s.Retry.getDelay(s.Attempt), true
+}
+
+func (s *Task) getRestartDelay(err error) (time.Duration, bool) {
+	if s.isStopped || s.Restart == nil || !s.Restart.isRestartable(err) {
+		return 0, false
+	}
+	return s.getNextRestartDelay(s.Restart)
+}
+
+// getProbeRestartDelay applies the restart limit and backoff to restarts requested by the probe,
+// without a restart policy the default delay is used
+func (s *Task) getProbeRestartDelay() (time.Duration, bool) {
+	if s.isStopped {
+		return 0, false
+	}
+	policy := s.Restart
+	if policy == nil {
+		policy = &RestartPolicy{}
+	}
+	return s.getNextRestartDelay(policy)
+}
+
+func (s *Task) getNextRestartDelay(policy *RestartPolicy) (time.Duration, bool) {
+	now := time.Now()
+	restartTimes := make([]time.Time, 0, len(s.restartTimes))
+	for _, t := range s.restartTimes {
+		if policy.Interval <= 0 || now.Sub(t) < time.Duration(policy.Interval)*time.Second {
+			restartTimes = append(restartTimes, t)
+		}
+	}
+	s.restartTimes = restartTimes
+
+	if policy.Limit > 0 && len(restartTimes) >= policy.Limit {
+		log.Println("Restart limit reached", s.Id)
+		return 0, false
+	}
+
+	s.restartTimes = append(s.restartTimes, now)
+	s.Restarts++
+
+	return getBackoffDelay(policy.Backoff, policy.Delay, policy.MaxDelay, len(s.restartTimes)), true
+}
+
+func (s *Task) scheduleRetry(config *cfg.Config, delay time.Duration) {
+	s.IsRetrying = true
+	s.IsTimeout = false
+	s.syncStatusAndSave()
+
+	go s.pushChanges(1)
+
+	s.retryTimer = time.AfterFunc(delay, func() {
+		s.IsRetrying = false
+		if err := s.start(config); err != nil {
+			log.Println("Retry task error", s.Id, err)
+			s.closeOutputs()
+			s.IsFinished = true
+			s.IsError = true
+			s.Error = err.Error()
+			s.syncStatusAndSave()
+			go s.pushChanges(0)
+			go s.queue.Schedule()
+		}
+	})
+}
+
+func (s *Task) cancelRetry() error {
+	if s.retryTimer != nil && !s.retryTimer.Stop() {
+		return errors.New("retry_already_started")
+	}
+	s.IsRetrying = false
+	s.closeOutputs()
+	s.IsCanceled = true
+	s.IsFinished = true
+	s.syncStatusAndSave()
+	go s.pushChanges(0)
+	go s.queue.Schedule()
+	return nil
+}
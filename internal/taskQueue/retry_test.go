@@ -0,0 +1,77 @@
+package taskQueue
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestProbeRestartLimit(t *testing.T) {
+	task := &Task{TaskBase: TaskBase{NewTaskBase: NewTaskBase{
+		Restart: &RestartPolicy{Policy: RESTART_NEVER, Limit: 2, Backoff: BACKOFF_EXPONENTIAL, Delay: 1},
+	}}}
+
+	expected := []time.Duration{time.Second, 2 * time.Second}
+	for i, delay := range expected {
+		got, ok := task.getProbeRestartDelay()
+		if !ok || got != delay {
+			t.Errorf("restart %d: expected %v, got %v %v", i, delay, got, ok)
+		}
+	}
+	if _, ok := task.getProbeRestartDelay(); ok {
+		t.Error("expected restart limit")
+	}
+	if task.Restarts != 2 {
+		t.Errorf("expected 2 restarts, got %d", task.Restarts)
+	}
+}
+
+func TestAttemptsLimit(t *testing.T) {
+	task := &Task{}
+	for i := 1; i <= MaxTaskAttempts+5; i++ {
+		task.Attempt = i
+		task.addAttempt(nil)
+	}
+
+	if len(task.Attempts) != MaxTaskAttempts {
+		t.Fatalf("expected %d attempts, got %d", MaxTaskAttempts, len(task.Attempts))
+	}
+	if task.Attempts[0].Attempt != 6 {
+		t.Errorf("expected the oldest attempts to be dropped, first is %d", task.Attempts[0].Attempt)
+	}
+}
+
+func TestTimeoutSkipsRetry(t *testing.T) {
+	process := exec.Command("sleep", "10")
+	if err := process.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		process.Wait()
+		close(done)
+	}()
+
+	task := &Task{process: process.Process, TaskBase: TaskBase{NewTaskBase: NewTaskBase{
+		Retry:   &RetryPolicy{MaxAttempts: 3},
+		Restart: &RestartPolicy{Policy: RESTART_ALWAYS},
+	}}}
+	task.timeout(done)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process is not terminated")
+	}
+	if !task.timedOut {
+		t.Error("task is not marked as timed out")
+	}
+	err := errors.New("signal: terminated")
+	if _, ok := task.getRetryDelay(err); ok {
+		t.Error("timed out task is retried")
+	}
+	if _, ok := task.getRestartDelay(err); ok {
+		t.Error("timed out task is restarted")
+	}
+}
@@ -0,0 +1,427 @@
+package taskQueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goTaskQueue/internal/cfg"
+	"goTaskQueue/internal/utils"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const ShimArg = "--shim"
+
+const SHIM_HELLO = "hello"
+const SHIM_DATA = "data"
+const SHIM_ACK = "ack"
+const SHIM_INPUT = "input"
+const SHIM_RESIZE = "resize"
+const SHIM_EXIT = "exit"
+const SHIM_DONE = "done"
+
+const ShimReconnectDelay = time.Second
+
+type TaskShim struct {
+	Pid       int       `json:"pid"`
+	Socket    string    `json:"socket"`
+	Cgroup    string    `json:"cgroup,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+type shimSpec struct {
+	Command    []string            `json:"command"`
+	Env        []string            `json:"env"`
+	Dir        string              `json:"dir"`
+	IsPty      bool                `json:"isPty"`
+	Streams    []string            `json:"streams"`
+	Spool      string              `json:"spool"`
+	Socket     string              `json:"socket"`
+	Cgroup     string              `json:"cgroup"`
+	Credential *syscall.Credential `json:"credential"`
+}
+
+type shimReady struct {
+	Pid   int    `json:"pid"`
+	Error string `json:"error"`
+}
+
+type shimMessage struct {
+	Type       string          `json:"type"`
+	Stream     string          `json:"stream,omitempty"`
+	Offset     int64           `json:"offset,omitempty"`
+	Data       []byte          `json:"data,omitempty"`
+	IsReset    bool            `json:"isReset,omitempty"`
+	Size       *PtyScreenSize  `json:"size,omitempty"`
+	ExitStatus *TaskExitStatus `json:"exitStatus,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+type shimClient struct {
+	socket  string
+	conn    net.Conn
+	enc     *json.Encoder
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+func (s *shimClient) send(msg shimMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(msg)
+}
+
+func (s *shimClient) Write(data []byte) (int, error) {
+	if err := s.send(shimMessage{Type: SHIM_INPUT, Data: data}); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (s *shimClient) resize(size *PtyScreenSize) error {
+	return s.send(shimMessage{Type: SHIM_RESIZE, Size: size})
+}
+
+func (s *shimClient) connect(isReset bool) error {
+	conn, err := net.Dial("unix", s.socket)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn = conn
+	s.enc = json.NewEncoder(conn)
+	s.mu.Unlock()
+
+	return s.send(shimMessage{Type: SHIM_HELLO, IsReset: isReset})
+}
+
+func (s *shimClient) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func newShimClient(socket string, streams []string) *shimClient {
+	client := &shimClient{
+		socket:  socket,
+		offsets: make(map[string]int64),
+	}
+	for _, stream := range streams {
+		client.offsets[stream] = 0
+	}
+	return client
+}
+
+func (s *Task) RunShim(config *cfg.Config) error {
+	runAs := config.Run
+	env, err := s.getEnvVariables(config)
+	if err != nil {
+		return err
+	}
+	if s.IsPty {
+		runAs = config.PtyRun
+		env = append(append([]string{}, config.PtyRunEnv...), env...)
+	}
+	command, err := s.getCommand(runAs)
+	if err != nil {
+		return err
+	}
+
+	var credential *syscall.Credential
+	if s.RunAs != nil {
+		if credential, _, err = s.RunAs.resolve(); err != nil {
+			return err
+		}
+	}
+
+	streams := s.getShimStreams()
+
+	output, err := s.getOutput(config, s.Combined, LOG_COMBINED, MemBufSize)
+	if err != nil {
+		return err
+	}
+	s.Combined = output
+
+	if len(streams) > 1 {
+		if s.Stdout, err = s.getOutput(config, s.Stdout, LOG_STDOUT, 0); err != nil {
+			return err
+		}
+		if s.Stderr, err = s.getOutput(config, s.Stderr, LOG_STDERR, 0); err != nil {
+			return err
+		}
+	} else if !s.IsPty {
+		s.Stdout = nil
+		s.Stderr = nil
+	}
+
+	if err := s.prepareCgroup(config); err != nil {
+		return err
+	}
+
+	// a retry may start before the shim of the previous attempt removes its socket and spool
+	socket := filepath.Join(getShimsPath(), fmt.Sprintf("%s-%d.sock", s.Id, s.Attempt))
+	pid, err := startShim(shimSpec{
+		Command:    command,
+		Env:        env,
+		Dir:        s.getWorkingDir(),
+		IsPty:      s.IsPty,
+		Streams:    streams,
+		Spool:      s.getLogFilename(config, fmt.Sprintf("shim-%d", s.Attempt)),
+		Socket:     socket,
+		Cgroup:     s.cgroup,
+		Credential: credential,
+	})
+	if err != nil {
+		s.removeCgroup()
+		return err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+
+	client := newShimClient(socket, streams)
+	if err := client.connect(true); err != nil {
+		process.Kill()
+		s.removeCgroup()
+		return err
+	}
+
+	s.Shim = &TaskShim{Pid: pid, Socket: socket, Cgroup: s.cgroup, StartedAt: time.Now()}
+	s.shim = client
+	s.stdin = client
+
+	s.onStart(config, process)
+
+	go s.readShim(config, client)
+
+	return nil
+}
+
+func (s *Task) attachShim(config *cfg.Config) error {
+	process, err := os.FindProcess(s.Shim.Pid)
+	if err != nil {
+		return err
+	}
+
+	// without log files the output is replayed from the shim spool
+	var streams []string
+	if s.Combined == nil {
+		streams = s.getShimStreams()
+		if s.Combined, err = s.getStdWriter(config, false, LOG_COMBINED, MemBufSize); err != nil {
+			return err
+		}
+		if len(streams) > 1 {
+			s.Stdout, _ = s.getStdWriter(config, false, LOG_STDOUT, 0)
+			s.Stderr, _ = s.getStdWriter(config, false, LOG_STDERR, 0)
+		}
+	}
+
+	client := newShimClient(s.Shim.Socket, streams)
+	if err := client.connect(streams != nil); err != nil {
+		return err
+	}
+
+	s.shim = client
+	s.stdin = client
+	s.cgroup = s.Shim.Cgroup
+
+	// the attempt keeps its start time, MaxRuntime counts the time before the reattach
+	s.attemptStart = s.Shim.StartedAt
+	if s.attemptStart.IsZero() {
+		s.attemptStart = time.Now()
+		if s.Attempt <= 1 {
+			s.attemptStart = s.StartedAt
+		}
+	}
+	s.attemptLog = 0
+
+	s.onAttach(config, process)
+
+	go s.readShim(config, client)
+
+	log.Println("Task attached to shim", s.Id)
+
+	return nil
+}
+
+func (s *Task) getShimStreams() []string {
+	streams := []string{LOG_COMBINED}
+	if !s.IsPty && !s.IsOnlyCombined {
+		streams = append(streams, LOG_STDOUT, LOG_STDERR)
+	}
+	return streams
+}
+
+func (s *Task) readShim(config *cfg.Config, client *shimClient) {
+	for {
+		err := s.readShimMessages(config, client)
+		if err == nil || s.isDetached {
+			return
+		}
+
+		time.Sleep(ShimReconnectDelay)
+		if rerr := client.connect(false); rerr != nil {
+			client.close()
+			s.onShimExit(config, nil, fmt.Errorf("shim connection lost: %w", err))
+			return
+		}
+	}
+}
+
+func (s *Task) readShimMessages(config *cfg.Config, client *shimClient) error {
+	client.mu.Lock()
+	dec := json.NewDecoder(client.conn)
+	client.mu.Unlock()
+
+	for {
+		var msg shimMessage
+		if err := dec.Decode(&msg); err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case SHIM_DATA:
+			s.onShimData(client, msg)
+		case SHIM_EXIT:
+			if err := client.send(shimMessage{Type: SHIM_DONE}); err != nil {
+				log.Println("Send shim done error", s.Id, err)
+			}
+			client.close()
+
+			var err error
+			if msg.Error != "" {
+				err = errors.New(msg.Error)
+			}
+			s.onShimExit(config, msg.ExitStatus, err)
+			return nil
+		}
+	}
+}
+
+func (s *Task) onShimData(client *shimClient, msg shimMessage) {
+	data := msg.Data
+	end := msg.Offset + int64(len(data))
+
+	if received, ok := client.offsets[msg.Stream]; ok {
+		if end <= received {
+			return
+		}
+		if msg.Offset < received {
+			data = data[received-msg.Offset:]
+		} else if msg.Offset > received {
+			log.Println("Shim output gap", s.Id, msg.Stream, msg.Offset-received)
+		}
+	}
+	client.offsets[msg.Stream] = end
+
+	switch msg.Stream {
+	case LOG_COMBINED:
+		s.writeCombined(data)
+	case LOG_STDOUT:
+		if s.Stdout != nil {
+			s.Stdout.Write(data)
+		}
+	case LOG_STDERR:
+		if s.Stderr != nil {
+			s.Stderr.Write(data)
+		}
+	}
+
+	if err := client.send(shimMessage{Type: SHIM_ACK, Stream: msg.Stream, Offset: end}); err != nil {
+		log.Println("Send shim ack error", s.Id, err)
+	}
+}
+
+// detachShim stops reading the shim output on shutdown, the task keeps running under the shim
+func (s *Task) detachShim() {
+	s.isDetached = true
+	if s.shim != nil {
+		s.shim.close()
+	}
+	s.closeOutputs()
+}
+
+func (s *Task) onShimExit(config *cfg.Config, exitStatus *TaskExitStatus, err error) {
+	s.Shim = nil
+	s.shim = nil
+
+	s.onExit(config, exitStatus, err)
+}
+
+// markCloseOnExec keeps descriptors opened without O_CLOEXEC, like the single instance lock,
+// out of the shim, the shim outlives the daemon and would hold them
+func markCloseOnExec() {
+	entries, err := os.ReadDir("/dev/fd")
+	if err != nil {
+		log.Println("Read descriptors error", err)
+		return
+	}
+	for _, entry := range entries {
+		if fd, err := strconv.Atoi(entry.Name()); err == nil && fd > 2 {
+			syscall.CloseOnExec(fd)
+		}
+	}
+}
+
+func startShim(spec shimSpec) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	markCloseOnExec()
+
+	process := exec.Command(executable, ShimArg)
+	process.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	process.Stdin = bytes.NewReader(data)
+	stdout, err := process.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := process.Start(); err != nil {
+		return 0, err
+	}
+
+	ready, err := utils.ParseJson[shimReady](stdout)
+
+	go func() {
+		if err := process.Wait(); err != nil {
+			log.Println("Shim exit error", err)
+		}
+	}()
+
+	if err != nil {
+		process.Process.Kill()
+		return 0, fmt.Errorf("start shim error: %w", err)
+	}
+	if ready.Error != "" {
+		return 0, errors.New(ready.Error)
+	}
+	return ready.Pid, nil
+}
+
+func getShimsPath() string {
+	return filepath.Join(cfg.GetProfilePath(), "shims")
+}
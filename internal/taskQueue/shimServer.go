@@ -0,0 +1,361 @@
+package taskQueue
+
+import (
+	"encoding/json"
+	"errors"
+	logstore "goTaskQueue/internal/logStore"
+	"goTaskQueue/internal/utils"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/creack/pty"
+)
+
+const ShimChunkSize = 16 * 1024
+
+type shimSpool struct {
+	store  *logstore.LogStore
+	writer *logstore.LogWriter
+}
+
+type shimServer struct {
+	spec     *shimSpec
+	listener net.Listener
+	spools   map[string]*shimSpool
+	acked    map[string]int64
+	mu       sync.Mutex
+	conn     net.Conn
+	enc      *json.Encoder
+	stdin    io.Writer
+	pty      *os.File
+	exit     *shimMessage
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// RunShim is the entry point of the shim process: it owns the task process
+// and its output, so the task keeps running when the daemon restarts
+func RunShim() {
+	signal.Ignore(syscall.SIGHUP, syscall.SIGPIPE)
+
+	ready := shimReady{}
+	shim, err := newShimServer(os.Stdin)
+	if err == nil {
+		ready.Pid, err = shim.start()
+	}
+	if err != nil {
+		ready.Error = err.Error()
+	}
+
+	json.NewEncoder(os.Stdout).Encode(ready)
+	os.Stdout.Close()
+
+	if shim == nil {
+		os.Exit(1)
+	}
+	if err == nil {
+		<-shim.done
+	}
+	shim.cleanup()
+}
+
+func newShimServer(r io.Reader) (*shimServer, error) {
+	spec, err := utils.ParseJson[shimSpec](r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(spec.Socket), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(spec.Socket); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", spec.Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &shimServer{
+		spec:     spec,
+		listener: listener,
+		spools:   make(map[string]*shimSpool),
+		acked:    make(map[string]int64),
+		done:     make(chan struct{}),
+	}
+
+	for _, stream := range spec.Streams {
+		filename := spec.Spool + "-" + stream
+		if store, err := logstore.OpenLogStore(filename); err == nil {
+			store.Remove()
+		}
+		store := logstore.NewLogStore(filename)
+		s.spools[stream] = &shimSpool{
+			store:  store,
+			writer: logstore.NewLogWriter(store),
+		}
+	}
+
+	go s.serve()
+
+	return s, nil
+}
+
+func (s *shimServer) start() (int, error) {
+	spec := s.spec
+	if len(spec.Command) == 0 {
+		return 0, errors.New("command_is_empty")
+	}
+
+	process := exec.Command(spec.Command[0], spec.Command[1:]...)
+	process.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Credential: spec.Credential}
+	process.Env = spec.Env
+	process.Dir = spec.Dir
+
+	closeCgroup, err := attachCgroup(spec.Cgroup, process)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	read := func(pipe io.Reader, stream string) {
+		defer wg.Done()
+		chunk := make([]byte, ShimChunkSize)
+		for {
+			bytes, err := pipe.Read(chunk)
+			if bytes > 0 {
+				s.write(stream, chunk[0:bytes])
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	if spec.IsPty {
+		f, err := pty.Start(process)
+		closeCgroup()
+		if err != nil {
+			return 0, err
+		}
+		s.pty = f
+		s.stdin = f
+
+		wg.Add(1)
+		go read(f, LOG_COMBINED)
+	} else {
+		stdin, _ := process.StdinPipe()
+		stdout, _ := process.StdoutPipe()
+		stderr, _ := process.StderrPipe()
+		s.stdin = stdin
+
+		err := process.Start()
+		closeCgroup()
+		if err != nil {
+			return 0, err
+		}
+
+		wg.Add(2)
+		go read(stdout, LOG_STDOUT)
+		go read(stderr, LOG_STDERR)
+	}
+
+	go func() {
+		wg.Wait()
+		err := process.Wait()
+		if s.pty != nil {
+			s.pty.Close()
+		}
+		s.onExit(process.ProcessState, err)
+	}()
+
+	return process.Process.Pid, nil
+}
+
+func (s *shimServer) write(stream string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{LOG_COMBINED}
+	if stream != LOG_COMBINED {
+		names = []string{stream, LOG_COMBINED}
+	}
+
+	for _, name := range names {
+		spool, ok := s.spools[name]
+		if !ok {
+			continue
+		}
+		offset := spool.store.Len()
+		if _, err := spool.writer.Write(data); err != nil {
+			continue
+		}
+		s.send(shimMessage{Type: SHIM_DATA, Stream: name, Offset: offset, Data: data})
+	}
+}
+
+func (s *shimServer) send(msg shimMessage) {
+	if s.enc == nil {
+		return
+	}
+	if err := s.enc.Encode(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		s.enc = nil
+	}
+}
+
+func (s *shimServer) onExit(state *os.ProcessState, err error) {
+	msg := shimMessage{Type: SHIM_EXIT, ExitStatus: NewExitStatus(state)}
+	if err != nil {
+		msg.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, spool := range s.spools {
+		spool.writer.Close()
+	}
+	s.exit = &msg
+	s.send(msg)
+}
+
+func (s *shimServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *shimServer) handle(conn net.Conn) {
+	defer s.detach(conn)
+
+	dec := json.NewDecoder(conn)
+	for {
+		var msg shimMessage
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case SHIM_HELLO:
+			s.attach(conn, msg.IsReset)
+		case SHIM_ACK:
+			s.mu.Lock()
+			s.acked[msg.Stream] = msg.Offset
+			s.mu.Unlock()
+		case SHIM_INPUT:
+			if s.stdin != nil {
+				s.stdin.Write(msg.Data)
+			}
+		case SHIM_RESIZE:
+			if s.pty != nil && msg.Size != nil {
+				pty.Setsize(s.pty, &pty.Winsize{
+					Rows: uint16(msg.Size.Rows),
+					Cols: uint16(msg.Size.Cols),
+					X:    uint16(msg.Size.X),
+					Y:    uint16(msg.Size.Y),
+				})
+			}
+		case SHIM_DONE:
+			s.mu.Lock()
+			isExited := s.exit != nil
+			s.mu.Unlock()
+			if isExited {
+				s.doneOnce.Do(func() {
+					close(s.done)
+				})
+				return
+			}
+		}
+	}
+}
+
+func (s *shimServer) attach(conn net.Conn, isReset bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil && s.conn != conn {
+		s.conn.Close()
+	}
+	s.conn = conn
+	s.enc = json.NewEncoder(conn)
+
+	for name, spool := range s.spools {
+		offset := s.acked[name]
+		if isReset {
+			offset = 0
+		}
+		s.replay(name, spool, offset)
+	}
+
+	if s.exit != nil {
+		s.send(*s.exit)
+	}
+}
+
+func (s *shimServer) replay(name string, spool *shimSpool, offset int64) {
+	if offset >= spool.store.Len() {
+		return
+	}
+
+	r := logstore.NewLogReader(spool.store)
+	defer r.Close()
+
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+
+	// reader returns EOF at the end of every chunk, two empty reads in a row mean the end of the store
+	chunk := make([]byte, ShimChunkSize)
+	isEmpty := false
+	for s.enc != nil {
+		bytes, err := r.Read(chunk)
+		if bytes > 0 {
+			s.send(shimMessage{Type: SHIM_DATA, Stream: name, Offset: offset, Data: chunk[0:bytes]})
+			offset += int64(bytes)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			break
+		}
+		if bytes == 0 {
+			if isEmpty {
+				break
+			}
+			isEmpty = true
+		} else {
+			isEmpty = false
+		}
+	}
+}
+
+func (s *shimServer) detach(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn.Close()
+	if s.conn == conn {
+		s.conn = nil
+		s.enc = nil
+	}
+}
+
+func (s *shimServer) cleanup() {
+	s.listener.Close()
+	os.Remove(s.spec.Socket)
+
+	for _, spool := range s.spools {
+		spool.writer.Close()
+		spool.store.Remove()
+	}
+}
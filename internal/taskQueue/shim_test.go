@@ -0,0 +1,97 @@
+package taskQueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readShimOutput(t *testing.T, client *shimClient, isAck bool) map[string]string {
+	client.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	dec := json.NewDecoder(client.conn)
+	output := make(map[string]string)
+	for {
+		var msg shimMessage
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		switch msg.Type {
+		case SHIM_DATA:
+			output[msg.Stream] += string(msg.Data)
+			if isAck && msg.Stream == LOG_COMBINED {
+				client.send(shimMessage{Type: SHIM_ACK, Stream: msg.Stream, Offset: msg.Offset + int64(len(msg.Data))})
+			}
+		case SHIM_EXIT:
+			return output
+		}
+	}
+}
+
+func TestShimReplay(t *testing.T) {
+	// unix socket paths are limited in length, the test dir may be too deep
+	dir, err := os.MkdirTemp("", "shim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	streams := []string{LOG_COMBINED, LOG_STDOUT, LOG_STDERR}
+	spec, _ := json.Marshal(shimSpec{
+		Command: []string{"sh", "-c", "printf out; printf err >&2"},
+		Streams: streams,
+		Spool:   filepath.Join(dir, "task-shim-1"),
+		Socket:  filepath.Join(dir, "task-1.sock"),
+	})
+	shim, err := newShimServer(bytes.NewReader(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shim.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newShimClient(shim.spec.Socket, streams)
+	if err := client.connect(true); err != nil {
+		t.Fatal(err)
+	}
+	output := readShimOutput(t, client, true)
+	if output[LOG_STDOUT] != "out" || output[LOG_STDERR] != "err" || len(output[LOG_COMBINED]) != 6 {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	// acks are handled asynchronously, wait for the last one before reconnecting
+	for i := 0; i < 100; i++ {
+		shim.mu.Lock()
+		acked := shim.acked[LOG_COMBINED]
+		shim.mu.Unlock()
+		if acked == 6 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// only not acknowledged streams are replayed after reconnect
+	if err := client.connect(false); err != nil {
+		t.Fatal(err)
+	}
+	output = readShimOutput(t, client, false)
+	if _, ok := output[LOG_COMBINED]; ok || output[LOG_STDOUT] != "out" || output[LOG_STDERR] != "err" {
+		t.Fatalf("unexpected replay %q", output)
+	}
+
+	client.send(shimMessage{Type: SHIM_DONE})
+	select {
+	case <-shim.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shim is not done")
+	}
+	client.close()
+	shim.cleanup()
+
+	if _, err := os.Stat(shim.spec.Socket); !os.IsNotExist(err) {
+		t.Errorf("socket is not removed: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package taskQueue
+
+import (
+	"goTaskQueue/internal/cfg"
+	"log"
+	"time"
+)
+
+const SHUTDOWN_DETACH = "detach"
+const SHUTDOWN_WAIT = "wait"
+const SHUTDOWN_STOP = "stop"
+
+const DefaultShutdownTimeout = 30
+const ShutdownPollInterval = 100 * time.Millisecond
+
+type DrainState struct {
+	IsDraining bool `json:"isDraining"`
+	Running    int  `json:"running"`
+	Pending    int  `json:"pending"`
+}
+
+func (s *Queue) SetDraining(isDraining bool) DrainState {
+	s.smu.Lock()
+	s.IsDraining = isDraining
+	s.smu.Unlock()
+
+	s.Save()
+
+	if !isDraining {
+		go s.Schedule()
+	}
+
+	return s.GetDrainState()
+}
+
+func (s *Queue) GetDrainState() DrainState {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	running, _ := s.getRunningCount()
+	return DrainState{
+		IsDraining: s.IsDraining,
+		Running:    running,
+		Pending:    len(s.getPendingTasks()),
+	}
+}
+
+func (s *Queue) Shutdown(config *cfg.Config) {
+	s.smu.Lock()
+	if s.isShutdown {
+		s.smu.Unlock()
+		return
+	}
+	s.isShutdown = true
+
+	running := make([]*Task, 0)
+	retrying := make([]*Task, 0)
+	for _, task := range s.getTasks() {
+		if task.IsRetrying {
+			retrying = append(retrying, task)
+		} else if task.IsStarted && !task.IsFinished {
+			running = append(running, task)
+		}
+	}
+	s.smu.Unlock()
+
+	for _, task := range retrying {
+		if err := task.cancelRetry(); err != nil {
+			log.Println("Cancel retry error", task.Id, err)
+		}
+	}
+
+	timeout := getSeconds(config.ShutdownTimeout, DefaultShutdownTimeout)
+
+	var stopTasks []*Task
+	var detachTasks []*Task
+	switch config.ShutdownMode {
+	case SHUTDOWN_WAIT:
+		for _, task := range running {
+			task.isStopped = true
+		}
+		log.Printf("Wait for %d running tasks\n", len(running))
+		waitTasks(running, timeout)
+		stopTasks = running
+	case SHUTDOWN_STOP:
+		stopTasks = running
+	default:
+		// tasks under shim keep running and are attached again on the next start
+		for _, task := range running {
+			if task.Shim == nil {
+				stopTasks = append(stopTasks, task)
+			} else {
+				detachTasks = append(detachTasks, task)
+			}
+		}
+	}
+
+	stopTasks = getUnfinishedTasks(stopTasks)
+	if len(stopTasks) > 0 {
+		log.Printf("Stop %d running tasks\n", len(stopTasks))
+		for _, task := range stopTasks {
+			if err := task.Signal(task.getStopSignal()); err != nil {
+				log.Println("Stop task error", task.Id, err)
+			}
+		}
+		if !waitTasks(stopTasks, timeout) {
+			for _, task := range getUnfinishedTasks(stopTasks) {
+				if err := task.Kill(); err != nil {
+					log.Println("Kill task error", task.Id, err)
+				}
+			}
+			waitTasks(stopTasks, time.Duration(DefaultStopTimeout)*time.Second)
+		}
+
+		for _, task := range getUnfinishedTasks(stopTasks) {
+			task.closeOutputs()
+			task.Shim = nil
+			task.IsCanceled = true
+			task.IsFinished = true
+			task.syncStatus()
+		}
+	}
+
+	if err := s.WriteQueue(); err != nil {
+		log.Println("Write queue error", err)
+	}
+
+	// the queue is written, the output of detached tasks is replayed from the shim on the next start
+	for _, task := range detachTasks {
+		task.detachShim()
+	}
+}
+
+func waitTasks(tasks []*Task, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for len(getUnfinishedTasks(tasks)) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(ShutdownPollInterval)
+	}
+	return true
+}
+
+func getUnfinishedTasks(tasks []*Task) []*Task {
+	result := make([]*Task, 0)
+	for _, task := range tasks {
+		if !task.IsFinished {
+			result = append(result, task)
+		}
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package taskQueue
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const StatsInterval = 2 * time.Second
+const StatsMaxSamples = 300
+
+type TaskStatsSample struct {
+	Time       time.Time `json:"time"`
+	Cpu        float64   `json:"cpu"`
+	Rss        int64     `json:"rss"`
+	Threads    int       `json:"threads"`
+	ReadBytes  int64     `json:"readBytes"`
+	WriteBytes int64     `json:"writeBytes"`
+}
+
+type TaskStats struct {
+	Id      string            `json:"id"`
+	Samples []TaskStatsSample `json:"samples"`
+}
+
+type taskStatsState struct {
+	mu       sync.Mutex
+	samples  []TaskStatsSample
+	cpuTicks int64
+	cpuTime  time.Time
+}
+
+type procStats struct {
+	cpuTicks   int64
+	rss        int64
+	threads    int
+	readBytes  int64
+	writeBytes int64
+}
+
+func (s *Queue) RunStats() {
+	for {
+		time.Sleep(StatsInterval)
+
+		for _, task := range s.getRunningTasks() {
+			if err := task.sampleStats(); err != nil {
+				log.Println("Sample task stats error", task.Id, err)
+			}
+		}
+	}
+}
+
+func (s *Queue) getRunningTasks() []*Task {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	tasks := make([]*Task, 0)
+	for _, task := range s.getTasks() {
+		if task.IsStarted && !task.IsFinished && !task.IsRetrying && task.process != nil {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+func (s *Queue) GetStats(id string) (*TaskStats, error) {
+	task, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &task.stats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	return &TaskStats{
+		Id:      task.Id,
+		Samples: append(make([]TaskStatsSample, 0, len(stats.samples)), stats.samples...),
+	}, nil
+}
+
+func (s *Task) getPids() ([]int, error) {
+	process := s.process
+	if process == nil {
+		return nil, errors.New("process_not_started")
+	}
+	if s.cgroup != "" {
+		if pids, err := s.getCgroupPids(); err == nil {
+			return pids, nil
+		}
+	}
+	pids, err := GetProcessPids(process.Pid)
+	if err != nil {
+		return []int{process.Pid}, nil
+	}
+	return pids, nil
+}
+
+func (s *Task) sampleStats() error {
+	pids, err := s.getPids()
+	if err != nil {
+		return err
+	}
+
+	var total procStats
+	for _, pid := range pids {
+		proc, err := readProcStats(pid)
+		if err != nil {
+			// process could exit between listing and reading
+			continue
+		}
+		total.cpuTicks += proc.cpuTicks
+		total.rss += proc.rss
+		total.threads += proc.threads
+		total.readBytes += proc.readBytes
+		total.writeBytes += proc.writeBytes
+	}
+
+	now := time.Now()
+	stats := &s.stats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	sample := TaskStatsSample{
+		Time:       now,
+		Rss:        total.rss,
+		Threads:    total.threads,
+		ReadBytes:  total.readBytes,
+		WriteBytes: total.writeBytes,
+	}
+	if !stats.cpuTime.IsZero() {
+		elapsed := now.Sub(stats.cpuTime).Seconds()
+		ticks := total.cpuTicks - stats.cpuTicks
+		if elapsed > 0 && ticks > 0 {
+			sample.Cpu = float64(ticks) / ClockTicks / elapsed * 100
+		}
+	}
+	stats.cpuTicks = total.cpuTicks
+	stats.cpuTime = now
+
+	stats.samples = append(stats.samples, sample)
+	if len(stats.samples) > StatsMaxSamples {
+		stats.samples = downsampleStats(stats.samples)
+	}
+	return nil
+}
+
+func (s *Task) resetStats() {
+	stats := &s.stats
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+
+	stats.samples = nil
+	stats.cpuTicks = 0
+	stats.cpuTime = time.Time{}
+}
+
+// Merge pairs of samples in the older half, so recent samples keep full resolution
+func downsampleStats(samples []TaskStatsSample) []TaskStatsSample {
+	half := len(samples) / 2
+	result := make([]TaskStatsSample, 0, len(samples))
+	for i := 0; i+1 < half; i += 2 {
+		a, b := samples[i], samples[i+1]
+		result = append(result, TaskStatsSample{
+			Time:       b.Time,
+			Cpu:        (a.Cpu + b.Cpu) / 2,
+			Rss:        max(a.Rss, b.Rss),
+			Threads:    max(a.Threads, b.Threads),
+			ReadBytes:  b.ReadBytes,
+			WriteBytes: b.WriteBytes,
+		})
+	}
+	if half%2 == 1 {
+		result = append(result, samples[half-1])
+	}
+	return append(result, samples[half:]...)
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package taskQueue
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// USER_HZ, fixed to 100 on all supported architectures
+const ClockTicks = 100
+
+var pageSize = int64(os.Getpagesize())
+
+func readProcStats(pid int) (*procStats, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// comm may contain spaces, fields start after the last ')'
+	idx := bytes.LastIndexByte(data, ')')
+	if idx == -1 {
+		return nil, errors.New("invalid_proc_stat")
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	if len(fields) < 22 {
+		return nil, errors.New("invalid_proc_stat")
+	}
+
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	threads, _ := strconv.Atoi(fields[17])
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+
+	stats := &procStats{
+		cpuTicks: utime + stime,
+		threads:  threads,
+		rss:      rss * pageSize,
+	}
+
+	// io is readable only for own processes
+	if f, err := os.Open(fmt.Sprintf("/proc/%d/io", pid)); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			name, value, ok := strings.Cut(scanner.Text(), ": ")
+			if !ok {
+				continue
+			}
+			switch name {
+			case "read_bytes":
+				stats.readBytes, _ = strconv.ParseInt(value, 10, 64)
+			case "write_bytes":
+				stats.writeBytes, _ = strconv.ParseInt(value, 10, 64)
+			}
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package taskQueue
+
+import (
+	"errors"
+)
+
+const ClockTicks = 100
+
+func readProcStats(pid int) (*procStats, error) {
+	return nil, errors.New("stats_unsupported")
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"syscall"
@@ -31,6 +32,8 @@ const LOG_COMBINED = "combined"
 const LOG_STDOUT = "out"
 const LOG_STDERR = "err"
 
+const DefaultStopTimeout = 10
+
 type TaskLink struct {
 	Name  string `json:"name"`
 	Type  string `json:"type"`
@@ -51,36 +54,69 @@ type PtyScreenSize struct {
 }
 
 type NewTaskBase struct {
-	Label            string `json:"label"`
-	Group            string `json:"group"`
-	IsPty            bool   `json:"isPty"`
-	IsOnlyCombined   bool   `json:"isOnlyCombined"`
-	IsSingleInstance bool   `json:"isSingleInstance"`
-	IsStartOnBoot    bool   `json:"isStartOnBoot"`
-	IsWriteLogs      bool   `json:"isWriteLogs"`
-	TTL              int64  `json:"ttl"`
+	Label            string           `json:"label"`
+	Group            string           `json:"group"`
+	IsPty            bool             `json:"isPty"`
+	IsOnlyCombined   bool             `json:"isOnlyCombined"`
+	IsSingleInstance bool             `json:"isSingleInstance"`
+	IsStartOnBoot    bool             `json:"isStartOnBoot"`
+	IsWriteLogs      bool             `json:"isWriteLogs"`
+	TTL              int64            `json:"ttl"`
+	MaxRuntime       int64            `json:"maxRuntime"`
+	StopSignal       int              `json:"stopSignal"`
+	StopTimeout      int64            `json:"stopTimeout"`
+	Retry            *RetryPolicy     `json:"retry"`
+	Priority         int              `json:"priority"`
+	Restart          *RestartPolicy   `json:"restart"`
+	Probe            *TaskProbe       `json:"probe"`
+	Resources        *TaskResources   `json:"resources"`
+	RunAs            *TaskCredential  `json:"runAs"`
+	WorkingDir       string           `json:"workingDir"`
+	Interpreter      *TaskInterpreter `json:"interpreter"`
 }
 
 type TaskBase struct {
-	Command       string `json:"command"`
-	TemplatePlace string `json:"templatePlace"`
+	Command          string            `json:"command"`
+	TemplatePlace    string            `json:"templatePlace"`
+	TemplateId       string            `json:"templateId"`
+	TemplateRevision string            `json:"templateRevision"`
+	TemplateChain    []string          `json:"templateChain,omitempty"`
+	Variables        map[string]string `json:"variables"`
+	DependsOn        []string          `json:"dependsOn"`
+	DoneDependencies []string          `json:"doneDependencies,omitempty"`
+	Env              map[string]string `json:"env"`
 	NewTaskBase
 }
 
+type TaskPreview struct {
+	TaskBase
+	EnvList     []string        `json:"envList"`
+	FullWorkDir string          `json:"fullWorkDir"`
+	Errors      []VariableError `json:"errors,omitempty"`
+}
+
 type Task struct {
 	TaskBase
 	Id             string `json:"id"`
-	process        *exec.Cmd
+	process        *os.Process
 	IsStarted      bool              `json:"isStarted"`
 	IsFinished     bool              `json:"isFinished"`
 	IsCanceled     bool              `json:"isCanceled"`
 	IsError        bool              `json:"isError"`
+	IsTimeout      bool              `json:"isTimeout"`
+	IsRetrying     bool              `json:"isRetrying"`
+	IsPending      bool              `json:"isPending"`
+	IsSkipped      bool              `json:"isSkipped"`
 	State          string            `json:"state"`
 	Stdout         *shared.DataStore `json:"-"`
 	Stderr         *shared.DataStore `json:"-"`
 	Combined       *shared.DataStore `json:"-"`
 	Error          string            `json:"error"`
+	Health         string            `json:"health"`
+	ProbeFailures  int               `json:"probeFailures"`
+	ExitStatus     *TaskExitStatus   `json:"exitStatus"`
 	CreatedAt      time.Time         `json:"createdAt"`
+	PendingAt      time.Time         `json:"pendingAt"`
 	StartedAt      time.Time         `json:"startedAt"`
 	FinishedAt     time.Time         `json:"finishedAt"`
 	ExpiresAt      time.Time         `json:"expiresAt"`
@@ -89,30 +125,135 @@ type Task struct {
 	qCh            []chan int
 	stdin          io.Writer
 	combinedOffset int64
+	done           chan struct{}
 	Links          []TaskLink `json:"links"`
 	queue          *Queue
-	Assets         []TaskAsset `json:"assets"`
+	Assets         []TaskAsset   `json:"assets"`
+	Attempt        int           `json:"attempt"`
+	Attempts       []TaskAttempt `json:"attempts"`
+	Restarts       int           `json:"restarts"`
+	restartTimes   []time.Time
+	isRestartProbe bool
+	attemptStart   time.Time
+	attemptLog     int64
+	retryTimer     *time.Timer
+	isStopped      bool
+	timedOut       bool
+	cgroup         string
+	stats          taskStatsState
+	shim           *shimClient
+	isDetached     bool
+	Shim           *TaskShim `json:"shim"`
 }
 
 func (s *Task) Run(config *cfg.Config, queue *Queue) error {
+	if s.IsPending {
+		return errors.New("task_already_pending")
+	}
+
+	if queue.isShutdown {
+		return errors.New("queue_is_shutting_down")
+	}
+
 	if s.IsSingleInstance && s.TemplatePlace != "" && queue.HasInstance(s.TemplatePlace) {
 		return fmt.Errorf("active instance exists %v", s.TemplatePlace)
 	}
 
-	if s.IsPty {
-		return s.RunPty(config)
+	s.Attempt = 0
+	s.Attempts = make([]TaskAttempt, 0)
+	s.Restarts = 0
+	s.restartTimes = nil
+	s.ExitStatus = nil
+	s.isStopped = false
+	s.IsFinished = false
+	s.IsCanceled = false
+	s.IsTimeout = false
+	s.IsSkipped = false
+	s.IsError = false
+	s.Error = ""
+	s.resetStats()
+
+	return queue.Enqueue(s)
+}
+
+func (s *Task) start(config *cfg.Config) error {
+	s.Attempt++
+	s.attemptLog = 0
+	if s.Attempt > 1 {
+		s.attemptLog = s.getCombinedLen()
+	}
+
+	var err error
+	if config.UseShim {
+		err = s.RunShim(config)
+	} else if s.IsPty {
+		err = s.RunPty(config)
 	} else {
-		return s.RunDirect(config)
+		err = s.RunDirect(config)
+	}
+	if err != nil {
+		s.removeScript()
 	}
+	return err
 }
 
-func (s *Task) getEnvVariables(config *cfg.Config) []string {
-	return append(config.RunEnv,
+// getEnvVariables returns the task environment, later entries win:
+// config env, task queue variables, run as user, .env files of parent templates and the template, per-run overrides
+func (s *Task) getEnvVariables(config *cfg.Config) ([]string, error) {
+	templatesPlace := GetTemplatesPath()
+	if s.TemplatePlace != "" {
+		if root, _, err := resolvePlace(s.TemplatePlace); err == nil {
+			templatesPlace = root.place
+		}
+	}
+
+	env := append(append([]string{}, config.RunEnv...),
 		"TASK_QUEUE_ID="+s.Id,
 		"TASK_QUEUE_URL="+config.GetBrowserAddress(),
 		"TASK_TEMPLATE_PLACE="+s.TemplatePlace,
-		"TASK_TEMPLATES_PLACE="+GetTemplatesPath(),
+		"TASK_TEMPLATES_PLACE="+templatesPlace,
 	)
+
+	if s.RunAs != nil {
+		_, credentialEnv, err := s.RunAs.resolve()
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, credentialEnv...)
+	}
+
+	if s.TemplatePlace != "" {
+		// parents are recorded when the task is created, so every attempt gets the same files
+		places := s.TemplateChain
+		if len(places) == 0 {
+			places = []string{s.TemplatePlace}
+		}
+		for _, relPlace := range places {
+			place, err := GetPlace(relPlace)
+			if err != nil {
+				return nil, err
+			}
+			fileEnv, err := readEnvFile(place)
+			if err != nil {
+				return nil, fmt.Errorf("read %v error: %w", ENV_NAME, err)
+			}
+			env = append(env, fileEnv...)
+		}
+	}
+
+	return append(env, getEnvList(s.Env)...), nil
+}
+
+func (s *Task) getTemplate() (*Template, error) {
+	if s.TemplatePlace != "" {
+		if template, err := ReadTemplate(s.TemplatePlace); err == nil {
+			return template, nil
+		}
+	}
+	if s.TemplateId != "" {
+		return GetTemplate(s.TemplateId)
+	}
+	return nil, errors.New("template_not_found")
 }
 
 func (s *Task) getWorkingDir() string {
@@ -124,31 +265,48 @@ func (s *Task) getWorkingDir() string {
 			fullPlace = place
 		}
 	}
+	if s.WorkingDir != "" {
+		if filepath.IsAbs(s.WorkingDir) {
+			return filepath.Clean(s.WorkingDir)
+		}
+		return filepath.Join(fullPlace, s.WorkingDir)
+	}
 	return fullPlace
 }
 
 func (s *Task) RunPty(config *cfg.Config) error {
-	runAs := config.PtyRun
-	runCommand := runAs[0]
-	runArgs := make([]string, 0)
-	if len(runAs) > 1 {
-		runArgs = append(runArgs, runAs[1:]...)
+	command, err := s.getCommand(config.PtyRun)
+	if err != nil {
+		return err
 	}
-	runArgs = append(runArgs, s.Command)
 
-	process := exec.Command(runCommand, runArgs...)
+	process := exec.Command(command[0], command[1:]...)
 	process.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
-	process.Env = append(append(process.Env, config.PtyRunEnv...), s.getEnvVariables(config)...)
+	env, err := s.getEnvVariables(config)
+	if err != nil {
+		return err
+	}
+	process.Env = append(append(process.Env, config.PtyRunEnv...), env...)
 	process.Dir = s.getWorkingDir()
+	if err := s.setCredential(process); err != nil {
+		return err
+	}
+
+	closeCgroup, err := s.setupCgroup(config, process)
+	if err != nil {
+		return err
+	}
 
 	f, err := pty.Start(process)
+	closeCgroup()
 	if err != nil {
+		s.removeCgroup()
 		return err
 	}
 
 	s.stdin = f
 
-	output, err := s.getStdWriter(config, s.IsWriteLogs, LOG_COMBINED, MemBufSize)
+	output, err := s.getOutput(config, s.Combined, LOG_COMBINED, MemBufSize)
 	if err != nil {
 		return err
 	}
@@ -162,23 +320,7 @@ func (s *Task) RunPty(config *cfg.Config) error {
 		for {
 			bytes, err := f.Read(chunk)
 			if bytes > 0 {
-				s.cmu.Lock()
-				if _, err := output.Write(chunk[0:bytes]); err != nil {
-					log.Println("Write output error", err)
-				}
-
-				if output.Len() > PtyTrimLimit {
-					if newOutput, err := output.Slice(PtyLogSize, true); err == nil {
-						// log.Println("trim")
-						approxOff := output.Len() - newOutput.Len()
-						output = newOutput
-						s.Combined = output
-						s.combinedOffset += approxOff
-					}
-				}
-				s.cmu.Unlock()
-
-				go s.pushChanges(1)
+				s.writeCombined(chunk[0:bytes])
 			}
 			if err != nil {
 				if !errors.Is(err, io.EOF) && !errors.Is(err, syscall.EIO) {
@@ -190,11 +332,7 @@ func (s *Task) RunPty(config *cfg.Config) error {
 		wg.Done()
 	}()
 
-	s.StartedAt = time.Now()
-
-	s.process = process
-	s.IsStarted = true
-	s.syncStatusAndSave()
+	s.onStart(config, process.Process)
 
 	go func() {
 		defer f.Close()
@@ -202,52 +340,36 @@ func (s *Task) RunPty(config *cfg.Config) error {
 		wg.Wait()
 		err = process.Wait()
 
-		s.FinishedAt = time.Now()
-
-		if s.Combined != nil {
-			s.cmu.RLock()
-			if err := s.Combined.Close(); err != nil {
-				log.Println("Close combined error", err)
-			}
-			s.cmu.RUnlock()
-		}
-
-		s.IsFinished = true
-		if err != nil {
-			s.IsError = true
-			s.Error = err.Error()
-		}
-
-		s.onFinish()
-
-		s.syncStatusAndSave()
-
-		go s.pushChanges(0)
+		s.onExit(config, NewExitStatus(process.ProcessState), err)
 	}()
 
 	return nil
 }
 
 func (s *Task) RunDirect(config *cfg.Config) error {
-	runAs := config.Run
-	runCommand := runAs[0]
-	runArgs := make([]string, 0)
-	if len(runAs) > 1 {
-		runArgs = append(runArgs, runAs[1:]...)
+	command, err := s.getCommand(config.Run)
+	if err != nil {
+		return err
 	}
-	runArgs = append(runArgs, s.Command)
 
-	process := exec.Command(runCommand, runArgs...)
+	process := exec.Command(command[0], command[1:]...)
 	process.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
-	process.Env = append(process.Env, s.getEnvVariables(config)...)
+	env, err := s.getEnvVariables(config)
+	if err != nil {
+		return err
+	}
+	process.Env = append(process.Env, env...)
 	process.Dir = s.getWorkingDir()
+	if err := s.setCredential(process); err != nil {
+		return err
+	}
 
 	const Out = LOG_STDOUT
 	const Err = LOG_STDERR
 
 	pipes := []string{Out, Err}
 
-	output, err := s.getStdWriter(config, s.IsWriteLogs, LOG_COMBINED, MemBufSize)
+	output, err := s.getOutput(config, s.Combined, LOG_COMBINED, MemBufSize)
 	if err != nil {
 		return err
 	}
@@ -264,7 +386,11 @@ func (s *Task) RunDirect(config *cfg.Config) error {
 		var pipe io.Reader
 		var buffer *shared.DataStore
 		if !s.IsOnlyCombined {
-			b, err := s.getStdWriter(config, s.IsWriteLogs, pT, 0)
+			current := s.Stdout
+			if pT == Err {
+				current = s.Stderr
+			}
+			b, err := s.getOutput(config, current, pT, 0)
 			if err != nil {
 				return err
 			}
@@ -287,21 +413,7 @@ func (s *Task) RunDirect(config *cfg.Config) error {
 						buffer.Write(chunk[0:bytes])
 					}
 
-					s.cmu.Lock()
-					output.Write(chunk[0:bytes])
-
-					if !s.IsOnlyCombined && output.Len() > CombinedLogTrimLimit {
-						if newOutput, err := output.Slice(CombinedLogSize, true); err == nil {
-							// log.Println("trim")
-							approxOff := output.Len() - newOutput.Len()
-							output = newOutput
-							s.Combined = output
-							s.combinedOffset += approxOff
-						}
-					}
-					s.cmu.Unlock()
-
-					go s.pushChanges(1)
+					s.writeCombined(chunk[0:bytes])
 				}
 				if err != nil {
 					if err != io.EOF {
@@ -314,16 +426,19 @@ func (s *Task) RunDirect(config *cfg.Config) error {
 		}()
 	}
 
-	err = process.Start()
+	closeCgroup, err := s.setupCgroup(config, process)
 	if err != nil {
 		return err
 	}
 
-	s.StartedAt = time.Now()
+	err = process.Start()
+	closeCgroup()
+	if err != nil {
+		s.removeCgroup()
+		return err
+	}
 
-	s.process = process
-	s.IsStarted = true
-	s.syncStatusAndSave()
+	s.onStart(config, process.Process)
 
 	go func() {
 		defer stdin.Close()
@@ -331,40 +446,135 @@ func (s *Task) RunDirect(config *cfg.Config) error {
 		wg.Wait()
 		err = process.Wait()
 
-		s.FinishedAt = time.Now()
+		s.onExit(config, NewExitStatus(process.ProcessState), err)
+	}()
 
-		if s.Stderr != nil {
-			if err := s.Stderr.Close(); err != nil {
-				log.Println("Close stderr error", err)
-			}
+	return nil
+}
+
+func (s *Task) onStart(config *cfg.Config, process *os.Process) {
+	now := time.Now()
+	if s.Attempt <= 1 {
+		s.StartedAt = now
+	}
+	s.attemptStart = now
+
+	s.onAttach(config, process)
+}
+
+func (s *Task) onAttach(config *cfg.Config, process *os.Process) {
+	s.process = process
+	s.IsStarted = true
+	s.done = make(chan struct{})
+	s.Health = ""
+	s.ProbeFailures = 0
+	s.isRestartProbe = false
+	s.timedOut = false
+	s.syncStatusAndSave()
+
+	s.watchRuntime()
+	s.watchProbe(config)
+}
+
+func (s *Task) onExit(config *cfg.Config, exitStatus *TaskExitStatus, err error) {
+	s.FinishedAt = time.Now()
+	s.ExitStatus = exitStatus
+	s.Health = ""
+	// the timeout is reported only after the process is gone
+	s.IsTimeout = s.timedOut
+	s.timedOut = false
+
+	s.addAttempt(err)
+	s.removeCgroup()
+	s.removeScript()
+
+	close(s.done)
+
+	if s.isRestartProbe {
+		if delay, ok := s.getProbeRestartDelay(); ok {
+			s.scheduleRetry(config, delay)
+			return
 		}
-		if s.Stdout != nil {
-			if err := s.Stdout.Close(); err != nil {
-				log.Println("Close stdout error", err)
-			}
+	}
+
+	if delay, ok := s.getRetryDelay(err); ok {
+		s.scheduleRetry(config, delay)
+		return
+	}
+
+	if delay, ok := s.getRestartDelay(err); ok {
+		s.scheduleRetry(config, delay)
+		return
+	}
+
+	s.closeOutputs()
+
+	s.IsFinished = true
+	if err != nil {
+		s.IsError = true
+		s.Error = err.Error()
+	}
+
+	s.onFinish()
+
+	s.syncStatusAndSave()
+
+	go s.pushChanges(0)
+
+	go s.queue.Schedule()
+}
+
+func (s *Task) closeOutputs() {
+	if s.Stderr != nil {
+		if err := s.Stderr.Close(); err != nil {
+			log.Println("Close stderr error", err)
 		}
-		if s.Combined != nil {
-			s.cmu.RLock()
-			if err := s.Combined.Close(); err != nil {
-				log.Println("Close combined error", err)
-			}
-			s.cmu.RUnlock()
+	}
+	if s.Stdout != nil {
+		if err := s.Stdout.Close(); err != nil {
+			log.Println("Close stdout error", err)
 		}
-
-		s.IsFinished = true
-		if err != nil {
-			s.IsError = true
-			s.Error = err.Error()
+	}
+	if s.Combined != nil {
+		s.cmu.RLock()
+		if err := s.Combined.Close(); err != nil {
+			log.Println("Close combined error", err)
 		}
+		s.cmu.RUnlock()
+	}
+}
 
-		s.onFinish()
+func (s *Task) writeCombined(data []byte) {
+	s.cmu.Lock()
+	output := s.Combined
+	if _, err := output.Write(data); err != nil {
+		log.Println("Write output error", err)
+	}
 
-		s.syncStatusAndSave()
+	trimLimit, logSize := int64(CombinedLogTrimLimit), int64(CombinedLogSize)
+	if s.IsPty {
+		trimLimit, logSize = PtyTrimLimit, PtyLogSize
+	}
+	if (s.IsPty || !s.IsOnlyCombined) && output.Len() > trimLimit {
+		if newOutput, err := output.Slice(logSize, true); err == nil {
+			// log.Println("trim")
+			approxOff := output.Len() - newOutput.Len()
+			s.Combined = newOutput
+			s.combinedOffset += approxOff
+		}
+	}
+	s.cmu.Unlock()
 
-		go s.pushChanges(0)
-	}()
+	go s.pushChanges(1)
+}
 
-	return nil
+func (s *Task) getCombinedLen() int64 {
+	s.cmu.RLock()
+	defer s.cmu.RUnlock()
+	if s.Combined == nil {
+		return 0
+	}
+	return s.combinedOffset + s.Combined.Len()
 }
 
 func (s *Task) ReadCombined(offset int64) (int64, []byte, error) {
@@ -417,6 +627,9 @@ func (s *Task) Resize(screenSize *PtyScreenSize) error {
 	if f, ok := s.stdin.(*os.File); ok {
 		return pty.Setsize(f, &ws)
 	}
+	if s.shim != nil {
+		return s.shim.resize(screenSize)
+	}
 	return nil
 }
 
@@ -436,11 +649,27 @@ func (s *Task) Kill() error {
 }
 
 func (s *Task) Signal(sig syscall.Signal) error {
+	if s.IsPending {
+		return s.queue.Dequeue(s)
+	}
+	if s.IsRetrying {
+		return s.cancelRetry()
+	}
+	if isStopSignal(sig) {
+		s.isStopped = true
+	}
+	return s.sendSignal(sig)
+}
+
+func (s *Task) sendSignal(sig syscall.Signal) error {
 	if s.IsFinished {
 		return errors.New("process_finished")
 	}
+	if ok, err := s.signalCgroup(sig); ok {
+		return err
+	}
 	if runtime.GOOS == "linux" {
-		if pids, err := GetProcessPids(s.process.Process.Pid); err == nil {
+		if pids, err := GetProcessPids(s.process.Pid); err == nil {
 			var err error
 			for _, pid := range pids {
 				suberr := syscall.Kill(pid, sig)
@@ -449,11 +678,71 @@ func (s *Task) Signal(sig syscall.Signal) error {
 			return err
 		} else {
 			log.Printf("Get child pids error, use default signal: %s\n", err)
-			return s.process.Process.Signal(sig)
+			return s.process.Signal(sig)
 		}
 	} else {
-		return s.process.Process.Signal(sig)
+		return s.process.Signal(sig)
+	}
+}
+
+func (s *Task) watchRuntime() {
+	if s.MaxRuntime <= 0 {
+		return
+	}
+
+	// a reattached task is stopped at once if the limit passed while the daemon was down
+	remaining := time.Duration(s.MaxRuntime)*time.Second - time.Since(s.attemptStart)
+	done := s.done
+	go func() {
+		if remaining > 0 {
+			select {
+			case <-done:
+				return
+			case <-time.After(remaining):
+			}
+		}
+
+		s.timeout(done)
+	}()
+}
+
+// timeout stops the task which exceeded MaxRuntime, a timed out task is not retried or restarted
+func (s *Task) timeout(done chan struct{}) {
+	s.timedOut = true
+	s.isStopped = true
+
+	s.terminate(done)
+}
+
+func (s *Task) terminate(done chan struct{}) {
+	if err := s.sendSignal(s.getStopSignal()); err != nil {
+		log.Println("Stop task error", s.Id, err)
+	}
+
+	select {
+	case <-done:
+		return
+	case <-time.After(s.getStopTimeout()):
+	}
+
+	if err := s.sendSignal(syscall.SIGKILL); err != nil {
+		log.Println("Kill task error", s.Id, err)
+	}
+}
+
+func (s *Task) getStopSignal() syscall.Signal {
+	if s.StopSignal > 0 {
+		return syscall.Signal(s.StopSignal)
 	}
+	return syscall.SIGTERM
+}
+
+func (s *Task) getStopTimeout() time.Duration {
+	timeout := s.StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	return time.Duration(timeout) * time.Second
 }
 
 func (s *Task) getLinkIndex(name string) int {
@@ -537,10 +826,18 @@ func (s *Task) pushChanges(value int) {
 func (s *Task) syncStatus() {
 	if s.IsCanceled {
 		s.State = "CANCELED"
+	} else if s.IsSkipped {
+		s.State = "SKIPPED"
+	} else if s.IsTimeout {
+		s.State = "TIMEOUT"
 	} else if s.IsError {
 		s.State = "ERROR"
 	} else if s.IsFinished {
 		s.State = "FINISHED"
+	} else if s.IsRetrying {
+		s.State = "RETRYING"
+	} else if s.IsPending {
+		s.State = "PENDING"
 	} else if s.IsStarted {
 		s.State = "STARTED"
 	} else {
@@ -564,7 +861,17 @@ func (s *Task) Init(config *cfg.Config, queue *Queue) {
 		}
 	}
 
+	if s.IsStarted && !s.IsFinished && !s.IsRetrying && s.Shim != nil {
+		err := s.attachShim(config)
+		if err == nil {
+			return
+		}
+		log.Println("Attach shim error", s.Id, err)
+	}
+
 	if s.IsStarted && !s.IsFinished {
+		s.Shim = nil
+		s.IsRetrying = false
 		s.IsCanceled = true
 		s.IsFinished = true
 		s.onFinish()
@@ -585,6 +892,13 @@ func (s *Task) openStdWriter(config *cfg.Config, postfix string) (*shared.DataSt
 	return l.GetDataStore(), nil
 }
 
+func (s *Task) getOutput(config *cfg.Config, current *shared.DataStore, postfix string, bufSize int) (*shared.DataStore, error) {
+	if s.Attempt > 1 && current != nil {
+		return current, nil
+	}
+	return s.getStdWriter(config, s.IsWriteLogs, postfix, bufSize)
+}
+
 func (s *Task) getStdWriter(config *cfg.Config, inLog bool, postfix string, bufSize int) (dataStore *shared.DataStore, err error) {
 	if inLog {
 		l := logstore.NewLogStore(s.getLogFilename(config, postfix))
@@ -604,7 +918,7 @@ func (s *Task) getLogFilename(c *cfg.Config, t string) string {
 }
 
 func (s *Task) onFinish() {
-	if s.IsCanceled || s.IsError {
+	if s.IsCanceled || s.IsTimeout || s.IsError {
 		return
 	}
 
@@ -613,12 +927,21 @@ func (s *Task) onFinish() {
 	}
 }
 
+func isStopSignal(sig syscall.Signal) bool {
+	switch sig {
+	case syscall.SIGKILL, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT:
+		return true
+	}
+	return false
+}
+
 func NewTask(id string, taskBase TaskBase) *Task {
 	task := Task{
 		TaskBase:  taskBase,
 		Id:        id,
 		CreatedAt: time.Now(),
 		Links:     make([]TaskLink, 0),
+		Attempts:  make([]TaskAttempt, 0),
 	}
 
 	task.syncStatus()
@@ -0,0 +1,533 @@
+package taskQueue
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"goTaskQueue/internal/cfg"
+	"goTaskQueue/internal/utils"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natefinch/atomic"
+)
+
+const HISTORY_FOLDER = "templateHistory"
+const REVISION_NAME = "revision.json"
+const MaxTemplateRevisions = 50
+const MaxDiffCells = 1 << 20
+
+const (
+	REVISION_WRITE    = "write"
+	REVISION_MOVE     = "move"
+	REVISION_REMOVE   = "remove"
+	REVISION_RESTORE  = "restore"
+	REVISION_EXTERNAL = "external"
+)
+
+// TemplateRevision describes a snapshot of template files, for a removal the snapshot is taken before removing
+type TemplateRevision struct {
+	Id          string    `json:"id"`
+	Place       string    `json:"place"`
+	PrevPlace   string    `json:"prevPlace,omitempty"`
+	Action      string    `json:"action"`
+	Comment     string    `json:"comment,omitempty"`
+	SourceId    string    `json:"sourceId,omitempty"`
+	CommandName string    `json:"commandName"`
+	Hash        string    `json:"hash"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type TemplateRevisionDiff struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Template string `json:"template"`
+	Command  string `json:"command"`
+}
+
+type templateFiles struct {
+	template    []byte
+	command     []byte
+	commandName string
+}
+
+// HISTORY_CACHE keeps revisions sorted by id, ids grow with time
+var HISTORY_CACHE []TemplateRevision
+var historyMu sync.Mutex
+var lastRevisionId int64
+
+func (s *templateFiles) getHash() string {
+	hash := sha256.New()
+	hash.Write(s.template)
+	hash.Write([]byte{0})
+	hash.Write(s.command)
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+func ListTemplateRevisions(relPlace string) ([]TemplateRevision, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	revisions, err := getRevisions()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TemplateRevision, 0)
+	for i := len(revisions) - 1; i >= 0; i-- {
+		revision := revisions[i]
+		if relPlace != "" && revision.Place != relPlace {
+			continue
+		}
+		result = append(result, revision)
+		// earlier revisions of a moved template are kept under the previous place
+		if relPlace != "" && revision.Action == REVISION_MOVE {
+			relPlace = revision.PrevPlace
+		}
+	}
+	return result, nil
+}
+
+// DiffTemplateRevisions compares two revisions, without toId the revision is compared with the current template
+func DiffTemplateRevisions(fromId string, toId string) (*TemplateRevisionDiff, error) {
+	from, fromFiles, err := readRevision(fromId)
+	if err != nil {
+		return nil, err
+	}
+
+	var toFiles *templateFiles
+	if toId != "" {
+		_, toFiles, err = readRevision(toId)
+	} else {
+		var place string
+		place, err = getPlace(getCurrentPlace(from))
+		if err == nil {
+			toFiles, err = readTemplateFiles(place)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateRevisionDiff{
+		From:     fromId,
+		To:       toId,
+		Template: diffLines(indentJson(fromFiles.template), indentJson(toFiles.template)),
+		Command:  diffLines(string(fromFiles.command), string(toFiles.command)),
+	}, nil
+}
+
+// RestoreTemplateRevision writes files of the revision to its place or to relPlace when it is set
+func RestoreTemplateRevision(id string, relPlace string, comment string) error {
+	revision, files, err := readRevision(id)
+	if err != nil {
+		return err
+	}
+	if relPlace == "" {
+		relPlace = revision.Place
+	}
+
+	place, err := getWritablePlace(relPlace)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ensureRevision(place); err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(place, 0700)
+	if err != nil {
+		return err
+	}
+
+	err = atomic.WriteFile(filepath.Join(place, TEMPALTE_NAME), bytes.NewReader(files.template))
+	if err != nil {
+		return err
+	}
+
+	err = atomic.WriteFile(filepath.Join(place, files.commandName), bytes.NewReader(files.command))
+	if err != nil {
+		return err
+	}
+
+	removeCommandFiles(place, files.commandName)
+
+	addRevision(place, TemplateRevision{
+		Action:   REVISION_RESTORE,
+		Comment:  comment,
+		SourceId: id,
+	})
+
+	FlushTemplateCache()
+
+	return nil
+}
+
+// ensureRevision records the current state of the template if it is not in the history,
+// so changes made outside of the API are not lost, returns the id of the current revision
+func ensureRevision(place string) (string, error) {
+	files, err := readTemplateFiles(place)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	relPlace, err := getRelPlace(place)
+	if err != nil {
+		return "", err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	revisions, err := getRevisions()
+	if err != nil {
+		return "", err
+	}
+
+	latest := findLatestRevision(revisions, relPlace)
+	if latest != nil && latest.Hash == files.getHash() {
+		return latest.Id, nil
+	}
+
+	revision, err := writeRevision(files, TemplateRevision{
+		Place:  relPlace,
+		Action: REVISION_EXTERNAL,
+	})
+	if err != nil {
+		return "", err
+	}
+	return revision.Id, nil
+}
+
+// addRevision records the template files of the place, errors are logged
+// because the template itself is already changed
+func addRevision(place string, revision TemplateRevision) {
+	if err := recordRevision(place, revision); err != nil {
+		log.Println("Add template revision error", place, err)
+	}
+}
+
+func recordRevision(place string, revision TemplateRevision) error {
+	files, err := readTemplateFiles(place)
+	if err != nil {
+		return err
+	}
+
+	revision.Place, err = getRelPlace(place)
+	if err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	if _, err := getRevisions(); err != nil {
+		return err
+	}
+
+	_, err = writeRevision(files, revision)
+	return err
+}
+
+// getCurrentPlace follows moves made after the revision
+func getCurrentPlace(revision *TemplateRevision) string {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	relPlace := revision.Place
+	for _, r := range HISTORY_CACHE {
+		if r.Id > revision.Id && r.Action == REVISION_MOVE && r.PrevPlace == relPlace {
+			relPlace = r.Place
+		}
+	}
+	return relPlace
+}
+
+func findLatestRevision(revisions []TemplateRevision, relPlace string) *TemplateRevision {
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].Place != relPlace {
+			continue
+		}
+		if revisions[i].Action == REVISION_REMOVE {
+			return nil
+		}
+		return &revisions[i]
+	}
+	return nil
+}
+
+func writeRevision(files *templateFiles, revision TemplateRevision) (*TemplateRevision, error) {
+	revision.Id = getRevisionId()
+	revision.CommandName = files.commandName
+	revision.Hash = files.getHash()
+	revision.CreatedAt = time.Now()
+
+	place := filepath.Join(getHistoryPath(), revision.Id)
+	if err := os.MkdirAll(place, 0700); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(place, TEMPALTE_NAME), files.template, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(place, files.commandName), files.command, 0600); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(revision)
+	if err != nil {
+		return nil, err
+	}
+	// revision.json is written last, folders without it are ignored
+	if err := atomic.WriteFile(filepath.Join(place, REVISION_NAME), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	HISTORY_CACHE = append(HISTORY_CACHE, revision)
+	pruneRevisions(revision.Place)
+
+	return &revision, nil
+}
+
+// pruneRevisions removes the oldest revisions of the place over MaxTemplateRevisions, historyMu must be held
+func pruneRevisions(relPlace string) {
+	count := 0
+	for _, revision := range HISTORY_CACHE {
+		if revision.Place == relPlace {
+			count++
+		}
+	}
+	if count <= MaxTemplateRevisions {
+		return
+	}
+
+	revisions := make([]TemplateRevision, 0, len(HISTORY_CACHE))
+	for _, revision := range HISTORY_CACHE {
+		if revision.Place == relPlace && count > MaxTemplateRevisions {
+			count--
+			if err := os.RemoveAll(filepath.Join(getHistoryPath(), revision.Id)); err != nil {
+				log.Println("Remove template revision error", revision.Id, err)
+			}
+			continue
+		}
+		revisions = append(revisions, revision)
+	}
+	HISTORY_CACHE = revisions
+}
+
+func readRevision(id string) (*TemplateRevision, *templateFiles, error) {
+	historyMu.Lock()
+	revisions, err := getRevisions()
+	historyMu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, revision := range revisions {
+		if revision.Id != id {
+			continue
+		}
+
+		place := filepath.Join(getHistoryPath(), revision.Id)
+		template, err := os.ReadFile(filepath.Join(place, TEMPALTE_NAME))
+		if err != nil {
+			return nil, nil, err
+		}
+		command, err := os.ReadFile(filepath.Join(place, revision.CommandName))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return &revision, &templateFiles{
+			template:    template,
+			command:     command,
+			commandName: revision.CommandName,
+		}, nil
+	}
+	return nil, nil, errors.New("revision_not_found")
+}
+
+func readTemplateFiles(place string) (*templateFiles, error) {
+	data, err := os.ReadFile(filepath.Join(place, TEMPALTE_NAME))
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := utils.ParseJson[Template](bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	commandName := getCommandName(template.Interpreter)
+	command, err := os.ReadFile(filepath.Join(place, commandName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &templateFiles{
+		template:    data,
+		command:     command,
+		commandName: commandName,
+	}, nil
+}
+
+// getRevisions loads the history on first use, historyMu must be held
+func getRevisions() ([]TemplateRevision, error) {
+	if HISTORY_CACHE != nil {
+		return HISTORY_CACHE, nil
+	}
+
+	revisions := make([]TemplateRevision, 0)
+	dir, err := os.ReadDir(getHistoryPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, entity := range dir {
+		if !entity.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(getHistoryPath(), entity.Name(), REVISION_NAME))
+		if err != nil {
+			continue
+		}
+		revision, err := utils.ParseJson[TemplateRevision](bytes.NewReader(data))
+		if err != nil {
+			log.Println("Read template revision error", entity.Name(), err)
+			continue
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Id < revisions[j].Id
+	})
+
+	HISTORY_CACHE = revisions
+	return HISTORY_CACHE, nil
+}
+
+// getRevisionId returns fixed width nanoseconds, historyMu must be held
+func getRevisionId() string {
+	id := time.Now().UnixNano()
+	if id <= lastRevisionId {
+		id = lastRevisionId + 1
+	}
+	lastRevisionId = id
+	return strconv.FormatInt(id, 10)
+}
+
+func getHistoryPath() string {
+	return filepath.Join(cfg.GetProfilePath(), HISTORY_FOLDER)
+}
+
+func indentJson(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return buf.String() + "\n"
+}
+
+// diffLines returns all lines of both texts prefixed with ' ', '-' or '+'
+func diffLines(from string, to string) string {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	var result strings.Builder
+	write := func(prefix string, line string) {
+		result.WriteString(prefix)
+		result.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			result.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+
+	for _, line := range a[:start] {
+		write(" ", line)
+	}
+	diffChangedLines(a[start:len(a)-end], b[start:len(b)-end], write)
+	for _, line := range a[len(a)-end:] {
+		write(" ", line)
+	}
+	return result.String()
+}
+
+// diffChangedLines looks for common lines with the LCS table, the changed part
+// over MaxDiffCells is written as removed and added lines
+func diffChangedLines(a []string, b []string, write func(prefix string, line string)) {
+	if len(a)*len(b) > MaxDiffCells {
+		for _, line := range a {
+			write("-", line)
+		}
+		for _, line := range b {
+			write("+", line)
+		}
+		return
+	}
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			write(" ", a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			write("-", a[i])
+			i++
+		default:
+			write("+", b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		write("-", a[i])
+	}
+	for ; j < len(b); j++ {
+		write("+", b[j])
+	}
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
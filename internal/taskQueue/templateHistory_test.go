@@ -0,0 +1,159 @@
+package taskQueue
+
+import (
+	"fmt"
+	"goTaskQueue/internal/cfg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	diff := diffLines("a\nb\nc\n", "a\nc\nd")
+
+	expected := " a\n-b\n c\n+d\n\\ No newline at end of file\n"
+	if diff != expected {
+		t.Errorf("expected %q, got %q", expected, diff)
+	}
+}
+
+func TestDiffLinesEqual(t *testing.T) {
+	diff := diffLines("x\ny\n", "x\ny\n")
+
+	expected := " x\n y\n"
+	if diff != expected {
+		t.Errorf("expected %q, got %q", expected, diff)
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	from := strings.Repeat("a\n", 2000)
+	to := strings.Repeat("b\n", 2000)
+	diff := diffLines("x\n"+from+"y\n", "x\n"+to+"y\n")
+
+	expected := " x\n" + strings.Repeat("-a\n", 2000) + strings.Repeat("+b\n", 2000) + " y\n"
+	if diff != expected {
+		t.Errorf("unexpected diff of large texts")
+	}
+}
+
+func setTestProfile(t *testing.T) {
+	cfg.PROFILE_PATH_CACHE = t.TempDir()
+	HISTORY_CACHE = nil
+	FlushTemplateCache()
+	t.Cleanup(func() {
+		cfg.PROFILE_PATH_CACHE = ""
+		HISTORY_CACHE = nil
+		FlushTemplateCache()
+	})
+}
+
+func getRevisionActions(t *testing.T, relPlace string) []string {
+	revisions, err := ListTemplateRevisions(relPlace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]string, 0, len(revisions))
+	for _, revision := range revisions {
+		actions = append(actions, revision.Action+":"+revision.Place)
+	}
+	return actions
+}
+
+func TestTemplateHistory(t *testing.T) {
+	setTestProfile(t)
+
+	if err := WriteTemplate(Template{Place: "a", Name: "a", Command: "echo 1\n"}, true, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTemplate(Template{Place: "a", Name: "a", Command: "echo 2\n"}, false, "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a change outside of the API is recorded when a task is created from the template
+	place, _ := GetPlace("a")
+	os.WriteFile(filepath.Join(place, COMMAND_NAME), []byte("echo 3\n"), 0600)
+	template, err := ReadTemplate("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := ListTemplateRevisions("a"); len(revisions) != 2 {
+		t.Errorf("reading the template records a revision: %+v", revisions)
+	}
+	revision := template.GetTaskBase().TemplateRevision
+	revisions, _ := ListTemplateRevisions("a")
+	if revision == "" || revisions[0].Id != revision || revisions[0].Action != REVISION_EXTERNAL {
+		t.Errorf("external change is not recorded: %v %+v", revision, revisions)
+	}
+
+	if err := MoveTemplate("a", "b", "move"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveTemplate("b", "remove"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"remove:b", "move:b", "external:a", "write:a", "write:a"}
+	if actions := getRevisionActions(t, "b"); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected %v, got %v", expected, actions)
+	}
+
+	first := revisions[len(revisions)-1]
+	if err := RestoreTemplateRevision(first.Id, "", "restore"); err != nil {
+		t.Fatal(err)
+	}
+	template, err = ReadTemplate("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.Command != "echo 1\n" {
+		t.Errorf("unexpected restored command %q", template.Command)
+	}
+	revisions, _ = ListTemplateRevisions("a")
+	if revisions[0].Action != REVISION_RESTORE || revisions[0].SourceId != first.Id || template.GetTaskBase().TemplateRevision != revisions[0].Id {
+		t.Errorf("unexpected restore revision %+v", revisions[0])
+	}
+}
+
+func TestTemplateHistoryMoveFolder(t *testing.T) {
+	setTestProfile(t)
+
+	for _, relPlace := range []string{"dir/x", "dir/sub/y"} {
+		if err := WriteTemplate(Template{Place: relPlace, Name: relPlace, Command: "echo\n"}, true, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := MoveTemplateFolder("dir", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"move:other/sub/y", "write:dir/sub/y"}
+	if actions := getRevisionActions(t, "other/sub/y"); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected %v, got %v", expected, actions)
+	}
+	expected = []string{"move:other/x", "write:dir/x"}
+	if actions := getRevisionActions(t, "other/x"); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected %v, got %v", expected, actions)
+	}
+}
+
+func TestTemplateHistoryPrune(t *testing.T) {
+	setTestProfile(t)
+
+	for i := 0; i < MaxTemplateRevisions+5; i++ {
+		if err := WriteTemplate(Template{Place: "a", Name: "a", Command: fmt.Sprintf("echo %d\n", i)}, i == 0, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	revisions, _ := ListTemplateRevisions("a")
+	if len(revisions) != MaxTemplateRevisions {
+		t.Errorf("expected %d revisions, got %d", MaxTemplateRevisions, len(revisions))
+	}
+	dir, _ := os.ReadDir(getHistoryPath())
+	if len(dir) != MaxTemplateRevisions {
+		t.Errorf("expected %d revision folders, got %d", MaxTemplateRevisions, len(dir))
+	}
+}
@@ -0,0 +1,209 @@
+package taskQueue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const LIB_FOLDER = "_lib"
+const MaxIncludeDepth = 10
+
+var includePattern = regexp.MustCompile(`^\s*(?:#|//)\s*@include\s+(\S+)\s*$`)
+
+// RenderTemplate returns the template merged with its parents, a parent command runs as a prelude
+// of the child command, zero fields and missing variables are taken from the parent.
+// A child can not turn off a flag or clear a number set by the parent, false and 0 mean "inherit"
+func RenderTemplate(template *Template) (*Template, error) {
+	chain, err := getTemplateChain(template)
+	if err != nil {
+		return nil, err
+	}
+
+	result := *chain[0]
+	for _, child := range chain[1:] {
+		result = mergeTemplate(&result, child)
+	}
+
+	if len(chain) > 1 {
+		result.chain = make([]string, 0, len(chain))
+		for _, t := range chain {
+			result.chain = append(result.chain, t.Place)
+		}
+	}
+	return &result, nil
+}
+
+// getTemplateChain returns parents of the template starting from the top one and the template itself
+func getTemplateChain(template *Template) ([]*Template, error) {
+	chain := []*Template{template}
+	isVisited := map[string]bool{template.Place: true}
+	for current := template; current.Extends != ""; {
+		parentPlace := strings.Trim(current.Extends, "/")
+		if isVisited[parentPlace] {
+			return nil, fmt.Errorf("template %v extends itself through %v", template.Place, parentPlace)
+		}
+		isVisited[parentPlace] = true
+
+		parent, err := ReadTemplate(parentPlace)
+		if err != nil {
+			return nil, fmt.Errorf("read parent template %v error: %w", parentPlace, err)
+		}
+		// the parent command is a prelude of the child script, both must be in the same language
+		if parent.Interpreter.getLanguage() != current.Interpreter.getLanguage() {
+			return nil, fmt.Errorf("template %v and its parent %v use different interpreters", current.Place, parentPlace)
+		}
+
+		chain = append([]*Template{parent}, chain...)
+		current = parent
+	}
+	return chain, nil
+}
+
+func mergeTemplate(parent *Template, child *Template) Template {
+	result := *child
+	result.Command = joinCommands(parent.Command, child.Command)
+	result.Variables = mergeVariables(parent.Variables, child.Variables)
+
+	p := parent.NewTaskBase
+	c := child.NewTaskBase
+	result.NewTaskBase = NewTaskBase{
+		Label:            inherit(c.Label, p.Label),
+		Group:            inherit(c.Group, p.Group),
+		IsPty:            inherit(c.IsPty, p.IsPty),
+		IsOnlyCombined:   inherit(c.IsOnlyCombined, p.IsOnlyCombined),
+		IsSingleInstance: inherit(c.IsSingleInstance, p.IsSingleInstance),
+		IsStartOnBoot:    inherit(c.IsStartOnBoot, p.IsStartOnBoot),
+		IsWriteLogs:      inherit(c.IsWriteLogs, p.IsWriteLogs),
+		TTL:              inherit(c.TTL, p.TTL),
+		MaxRuntime:       inherit(c.MaxRuntime, p.MaxRuntime),
+		StopSignal:       inherit(c.StopSignal, p.StopSignal),
+		StopTimeout:      inherit(c.StopTimeout, p.StopTimeout),
+		Retry:            inherit(c.Retry, p.Retry),
+		Priority:         inherit(c.Priority, p.Priority),
+		Restart:          inherit(c.Restart, p.Restart),
+		Probe:            inherit(c.Probe, p.Probe),
+		Resources:        inherit(c.Resources, p.Resources),
+		RunAs:            inherit(c.RunAs, p.RunAs),
+		WorkingDir:       inherit(c.WorkingDir, p.WorkingDir),
+		Interpreter:      inherit(c.Interpreter, p.Interpreter),
+	}
+	return result
+}
+
+func inherit[T comparable](value T, parent T) T {
+	var zero T
+	if value == zero {
+		return parent
+	}
+	return value
+}
+
+func joinCommands(prelude string, command string) string {
+	if prelude == "" {
+		return command
+	}
+	if !strings.HasSuffix(prelude, "\n") {
+		prelude += "\n"
+	}
+	return prelude + command
+}
+
+// mergeVariables keeps the order of parent variables, a child variable with the same value replaces the parent one
+func mergeVariables(parent []TemplateVariable, child []TemplateVariable) []TemplateVariable {
+	result := make([]TemplateVariable, 0, len(parent)+len(child))
+	index := make(map[string]int)
+	for _, variable := range parent {
+		index[variable.Value] = len(result)
+		result = append(result, variable)
+	}
+	for _, variable := range child {
+		if i, ok := index[variable.Value]; ok {
+			result[i] = variable
+			continue
+		}
+		index[variable.Value] = len(result)
+		result = append(result, variable)
+	}
+	return result
+}
+
+// expandIncludes replaces "# @include name" lines with files from _lib folders,
+// the root of the template is searched first, then all roots in order
+func expandIncludes(command string, relPlace string) (string, error) {
+	if !strings.Contains(command, "@include") {
+		return command, nil
+	}
+
+	libs := make([]string, 0)
+	if _, place, err := resolvePlace(relPlace); err == nil {
+		if root, ok := getPlaceRoot(place); ok {
+			libs = append(libs, filepath.Join(root.place, LIB_FOLDER))
+		}
+	}
+	for _, root := range getTemplateRoots() {
+		libs = append(libs, filepath.Join(root.place, LIB_FOLDER))
+	}
+
+	return includeFiles(command, libs, 0)
+}
+
+func includeFiles(command string, libs []string, depth int) (string, error) {
+	if depth > MaxIncludeDepth {
+		return "", errors.New("include_depth_exceeded")
+	}
+
+	lines := strings.SplitAfter(command, "\n")
+	var result strings.Builder
+	for _, line := range lines {
+		match := includePattern.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if match == nil {
+			result.WriteString(line)
+			continue
+		}
+
+		data, err := readInclude(libs, match[1])
+		if err != nil {
+			return "", err
+		}
+
+		snippet, err := includeFiles(string(data), libs, depth+1)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(snippet)
+		if strings.HasSuffix(line, "\n") && !strings.HasSuffix(snippet, "\n") {
+			result.WriteString("\n")
+		}
+	}
+	return result.String(), nil
+}
+
+func readInclude(libs []string, name string) ([]byte, error) {
+	// the name can not point outside of the _lib folder
+	cleanName := filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+name), "/"))
+	for _, lib := range libs {
+		data, err := os.ReadFile(filepath.Join(lib, cleanName))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("include %v not found", name)
+}
+
+func isLibFolder(place string) bool {
+	for _, root := range getTemplateRoots() {
+		lib := filepath.Join(root.place, LIB_FOLDER)
+		if place == lib || strings.HasPrefix(place, lib+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package taskQueue
+
+import (
+	"goTaskQueue/internal/cfg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	parent := &Template{
+		Command: "set -e",
+		Variables: []TemplateVariable{
+			{Name: "Host", Value: "host", DefaultValue: "localhost"},
+			{Name: "Port", Value: "port", DefaultValue: "80"},
+		},
+		NewTaskBase: NewTaskBase{Group: "deploy", IsWriteLogs: true, TTL: 60},
+	}
+	child := &Template{
+		Place:   "app",
+		Command: "echo {host}:{port}\n",
+		Variables: []TemplateVariable{
+			{Name: "App port", Value: "port", DefaultValue: "8080"},
+			{Name: "Name", Value: "name"},
+		},
+		NewTaskBase: NewTaskBase{TTL: 10},
+	}
+
+	result := mergeTemplate(parent, child)
+
+	if result.Command != "set -e\necho {host}:{port}\n" {
+		t.Errorf("unexpected command %q", result.Command)
+	}
+	values := make([]string, 0)
+	for _, variable := range result.Variables {
+		values = append(values, variable.Value+"="+variable.DefaultValue)
+	}
+	if expected := []string{"host=localhost", "port=8080", "name="}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %q, got %q", expected, values)
+	}
+	if result.Place != "app" || result.Group != "deploy" || !result.IsWriteLogs || result.TTL != 10 {
+		t.Errorf("unexpected fields %+v", result)
+	}
+}
+
+func TestIncludeFiles(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+	os.WriteFile(filepath.Join(first, "setup.sh"), []byte("# @include common.sh\ncd /tmp"), 0600)
+	os.WriteFile(filepath.Join(second, "common.sh"), []byte("set -e\n"), 0600)
+
+	command, err := includeFiles("#!/bin/sh\n  # @include setup.sh\necho ok\n", []string{first, second}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "#!/bin/sh\nset -e\ncd /tmp\necho ok\n"; command != expected {
+		t.Errorf("expected %q, got %q", expected, command)
+	}
+
+	if _, err := includeFiles("# @include missing.sh", []string{first}, 0); err == nil {
+		t.Error("expected missing include error")
+	}
+}
+
+func TestIncludeFilesCycle(t *testing.T) {
+	lib := t.TempDir()
+	os.WriteFile(filepath.Join(lib, "loop.sh"), []byte("// @include loop.sh\n"), 0600)
+
+	if _, err := includeFiles("# @include loop.sh", []string{lib}, 0); err == nil {
+		t.Error("expected include depth error")
+	}
+}
+
+func TestTemplateChain(t *testing.T) {
+	setTestProfile(t)
+
+	if err := WriteTemplate(Template{Place: "parent", Name: "parent", Command: "set -e\n"}, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTemplate(Template{Place: "child", Name: "child", Command: "echo\n", Extends: "parent"}, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	parentPlace, _ := GetPlace("parent")
+	os.WriteFile(filepath.Join(parentPlace, ENV_NAME), []byte("A=1\n"), 0600)
+
+	python := Template{Place: "py", Name: "py", Command: "print(1)\n", Extends: "parent"}
+	python.Interpreter = &TaskInterpreter{Command: []string{"python3"}}
+	if err := WriteTemplate(python, true, ""); err == nil {
+		t.Error("expected interpreter mismatch error")
+	}
+
+	template, err := ReadTemplate("child")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := RenderTemplate(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &Task{TaskBase: rendered.GetTaskBase()}
+	if expected := []string{"parent", "child"}; !reflect.DeepEqual(task.TemplateChain, expected) {
+		t.Errorf("expected chain %v, got %v", expected, task.TemplateChain)
+	}
+
+	// the chain of the task is kept when the template stops extending the parent
+	if err := WriteTemplate(Template{Place: "child", Name: "child", Command: "echo\n"}, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	env, err := task.getEnvVariables(&cfg.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(env, "A=1") {
+		t.Errorf("parent env is lost: %v", env)
+	}
+}
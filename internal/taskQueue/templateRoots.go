@@ -0,0 +1,129 @@
+package taskQueue
+
+import (
+	"errors"
+	"goTaskQueue/internal/cfg"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type templateRoot struct {
+	place      string
+	prefix     string
+	isReadOnly bool
+}
+
+var TEMPLATE_ROOTS []templateRoot
+var templateRootsMu sync.RWMutex
+
+// SetTemplateRoots sets additional template roots, the profile templates folder is always the root without prefix
+func SetTemplateRoots(roots []cfg.TemplateRoot) {
+	result := make([]templateRoot, 0, len(roots))
+	prefixes := make(map[string]bool)
+	for _, root := range roots {
+		prefix := strings.Trim(root.Prefix, "/")
+		if prefix == "" || strings.ContainsAny(prefix, "/\\") || prefixes[prefix] {
+			log.Println("Invalid template root prefix", root.Prefix)
+			continue
+		}
+		prefixes[prefix] = true
+
+		place := root.Place
+		if !filepath.IsAbs(place) {
+			place = filepath.Join(cfg.GetProfilePath(), place)
+		}
+
+		result = append(result, templateRoot{
+			place:      filepath.Clean(place),
+			prefix:     prefix,
+			isReadOnly: root.IsReadOnly,
+		})
+	}
+
+	templateRootsMu.Lock()
+	TEMPLATE_ROOTS = result
+	templateRootsMu.Unlock()
+
+	FlushTemplateCache()
+	watchTemplateRoots()
+}
+
+func getTemplateRoots() []templateRoot {
+	templateRootsMu.RLock()
+	defer templateRootsMu.RUnlock()
+
+	roots := make([]templateRoot, 0, len(TEMPLATE_ROOTS)+1)
+	roots = append(roots, templateRoot{place: getTemplatesPath()})
+	return append(roots, TEMPLATE_ROOTS...)
+}
+
+// resolvePlace returns the root and the full path of the template place,
+// the first segment of the place selects the root by its prefix
+func resolvePlace(relPlace string) (templateRoot, string, error) {
+	if filepath.Separator != '/' && strings.ContainsRune(relPlace, filepath.Separator) {
+		return templateRoot{}, "", errors.New("invalid character in file path")
+	}
+
+	cleanPlace := strings.TrimPrefix(path.Clean("/"+relPlace), "/")
+	roots := getTemplateRoots()
+	root := roots[0]
+	for _, r := range roots[1:] {
+		if cleanPlace == r.prefix || strings.HasPrefix(cleanPlace, r.prefix+"/") {
+			root = r
+			cleanPlace = strings.TrimPrefix(strings.TrimPrefix(cleanPlace, r.prefix), "/")
+			break
+		}
+	}
+
+	return root, filepath.Join(root.place, filepath.FromSlash(cleanPlace)), nil
+}
+
+// getWritablePlace is getPlace for changing operations, read-only roots and root folders are refused
+func getWritablePlace(relPlace string) (string, error) {
+	root, place, err := resolvePlace(relPlace)
+	if err != nil {
+		return "", err
+	}
+	if root.isReadOnly {
+		return "", errors.New("template_root_is_read_only")
+	}
+	if place == root.place {
+		return "", errors.New("invalid_template_place")
+	}
+	return place, nil
+}
+
+// getPlaceRoot returns the root that contains the path, nested roots win
+func getPlaceRoot(place string) (templateRoot, bool) {
+	var result templateRoot
+	isFound := false
+	for _, root := range getTemplateRoots() {
+		if place != root.place && !strings.HasPrefix(place, root.place+string(filepath.Separator)) {
+			continue
+		}
+		if !isFound || len(root.place) > len(result.place) {
+			result = root
+			isFound = true
+		}
+	}
+	return result, isFound
+}
+
+func isReadOnlyPlace(place string) bool {
+	root, ok := getPlaceRoot(place)
+	return ok && root.isReadOnly
+}
+
+// isForeignFolder reports folders that are another root or hidden by a root prefix
+func isForeignFolder(place string) bool {
+	for _, root := range getTemplateRoots() {
+		if place == root.place {
+			return true
+		}
+	}
+	_, err := getRelPlace(place)
+	return err != nil
+}
@@ -0,0 +1,226 @@
+package taskQueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"goTaskQueue/internal/cfg"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/natefinch/atomic"
+)
+
+const MISSED_RUN_SKIP = "skip"
+const MISSED_RUN_ONCE = "once"
+
+const ScheduleCheckInterval = time.Minute
+
+type TemplateSchedule struct {
+	Cron      string     `json:"cron"`
+	Timezone  string     `json:"timezone"`
+	MissedRun string     `json:"missedRun"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	IsPaused  bool       `json:"isPaused,omitempty"`
+}
+
+type ScheduleState struct {
+	IsPaused    bool      `json:"isPaused"`
+	LastCheckAt time.Time `json:"lastCheckAt"`
+	LastRunAt   time.Time `json:"lastRunAt"`
+}
+
+type ScheduleStore struct {
+	States map[string]*ScheduleState `json:"states"`
+	mu     sync.Mutex
+}
+
+func (s *TemplateSchedule) getKey(place string) string {
+	return place + "|" + s.Cron + "|" + s.Timezone
+}
+
+func (s *TemplateSchedule) getNext(from time.Time) (time.Time, error) {
+	expr, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.Local
+	if s.Timezone != "" {
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return time.Time{}, err
+		}
+	}
+	next := expr.Next(from.In(loc))
+	if next.IsZero() {
+		return next, errors.New("schedule_has_no_next_run")
+	}
+	return next, nil
+}
+
+func (s *ScheduleStore) getState(key string) *ScheduleState {
+	state, ok := s.States[key]
+	if !ok {
+		state = &ScheduleState{LastCheckAt: time.Now()}
+		s.States[key] = state
+	}
+	return state
+}
+
+func (s *ScheduleStore) write() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return atomic.WriteFile(getSchedulesPath(), bytes.NewReader(data))
+}
+
+func (s *Queue) RunSchedules() {
+	for {
+		s.checkSchedules(time.Now())
+
+		now := time.Now()
+		time.Sleep(now.Truncate(ScheduleCheckInterval).Add(ScheduleCheckInterval).Sub(now))
+	}
+}
+
+func (s *Queue) checkSchedules(now time.Time) {
+	store := s.schedules
+	store.mu.Lock()
+
+	runTemplates := make([]Template, 0)
+	for _, template := range GetTemplates() {
+		// every schedule state is updated, the template runs once even if several schedules are due
+		isDue := false
+		for _, schedule := range template.Schedules {
+			state := store.getState(schedule.getKey(template.Place))
+			lastCheckAt := state.LastCheckAt
+			state.LastCheckAt = now
+
+			if state.IsPaused {
+				continue
+			}
+
+			next, err := schedule.getNext(lastCheckAt)
+			if err != nil {
+				log.Printf("Template '%s' schedule '%s' error: %v\n", template.Place, schedule.Cron, err)
+				continue
+			}
+			if next.After(now) {
+				continue
+			}
+
+			isMissed := now.Sub(next) >= ScheduleCheckInterval
+			if isMissed && schedule.MissedRun != MISSED_RUN_ONCE {
+				log.Printf("Skip missed run of template '%s' scheduled at %v\n", template.Place, next)
+				continue
+			}
+
+			state.LastRunAt = now
+			isDue = true
+		}
+		if isDue {
+			runTemplates = append(runTemplates, template)
+		}
+	}
+
+	if err := store.write(); err != nil {
+		log.Println("Write schedules error", err)
+	}
+	store.mu.Unlock()
+
+	for _, template := range runTemplates {
+		if err := s.RunTemplate(&template); err != nil {
+			log.Printf("Run scheduled template '%s' error: %v\n", template.Place, err)
+		}
+	}
+}
+
+func (s *Queue) RunTemplate(template *Template) error {
+	template, err := RenderTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	taskBase := template.GetTaskBase()
+	if err := ApplyTemplateVariables(s.config, &taskBase, template, nil); err != nil {
+		return err
+	}
+
+	task, err := s.Add(s.config, taskBase)
+	if err != nil {
+		return err
+	}
+	return task.Run(s.config, s)
+}
+
+func (s *Queue) PauseSchedule(place string, index int, isPaused bool) error {
+	template, err := ReadTemplate(place)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(template.Schedules) {
+		return errors.New("schedule_not_found")
+	}
+
+	store := s.schedules
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	state := store.getState(template.Schedules[index].getKey(template.Place))
+	state.IsPaused = isPaused
+	state.LastCheckAt = time.Now()
+
+	return store.write()
+}
+
+func (s *Queue) WithSchedules(templates []Template) []Template {
+	store := s.schedules
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	result := make([]Template, len(templates))
+	for i, template := range templates {
+		if len(template.Schedules) > 0 {
+			schedules := make([]TemplateSchedule, len(template.Schedules))
+			for j, schedule := range template.Schedules {
+				schedule.IsPaused = false
+				schedule.NextRunAt = nil
+				if state, ok := store.States[schedule.getKey(template.Place)]; ok && state.IsPaused {
+					schedule.IsPaused = true
+				} else if next, err := schedule.getNext(time.Now()); err == nil {
+					schedule.NextRunAt = &next
+				}
+				schedules[j] = schedule
+			}
+			template.Schedules = schedules
+		}
+		result[i] = template
+	}
+	return result
+}
+
+func loadScheduleStore() *ScheduleStore {
+	store := &ScheduleStore{
+		States: make(map[string]*ScheduleState),
+	}
+
+	data, err := os.ReadFile(getSchedulesPath())
+	if err == nil {
+		err = json.Unmarshal(data, store)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("Load schedules error", err)
+	}
+	if store.States == nil {
+		store.States = make(map[string]*ScheduleState)
+	}
+
+	return store
+}
+
+func getSchedulesPath() string {
+	return filepath.Join(cfg.GetProfilePath(), "schedules.json")
+}
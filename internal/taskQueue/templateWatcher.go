@@ -0,0 +1,223 @@
+package taskQueue
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TemplatesWatchDelay = 200 * time.Millisecond
+
+type TemplateEvent struct {
+	Places []string `json:"places"`
+}
+
+type templateChange struct {
+	place string
+	isDir bool
+}
+
+var templateSubscribers = make(map[chan TemplateEvent]struct{})
+var templateSubscribersMu sync.Mutex
+
+var templateChanges chan templateChange
+var watchedRoots = make(map[string]func())
+var watchedRootsMu sync.Mutex
+
+// RunTemplateWatcher refreshes the templates cache when files in template roots are changed
+func RunTemplateWatcher() {
+	watchedRootsMu.Lock()
+	templateChanges = make(chan templateChange, 64)
+	watchedRootsMu.Unlock()
+
+	watchTemplateRoots()
+
+	pending := make([]templateChange, 0)
+	timer := time.NewTimer(TemplatesWatchDelay)
+	timer.Stop()
+	for {
+		select {
+		case change := <-templateChanges:
+			pending = append(pending, change)
+			timer.Reset(TemplatesWatchDelay)
+		case <-timer.C:
+			places := refreshTemplates(pending)
+			pending = make([]templateChange, 0)
+			flushOptionsCache()
+			if len(places) > 0 {
+				publishTemplateEvent(TemplateEvent{Places: places})
+			}
+		}
+	}
+}
+
+// watchTemplateRoots starts watching roots added since the last call and stops watching removed ones
+func watchTemplateRoots() {
+	watchedRootsMu.Lock()
+	defer watchedRootsMu.Unlock()
+
+	if templateChanges == nil {
+		return
+	}
+
+	isRoot := make(map[string]bool)
+	for _, root := range getTemplateRoots() {
+		isRoot[root.place] = true
+		if _, ok := watchedRoots[root.place]; ok {
+			continue
+		}
+		stop, err := watchTemplates(root.place, templateChanges)
+		if err != nil {
+			log.Println("Watch templates error", root.place, err)
+			continue
+		}
+		watchedRoots[root.place] = stop
+	}
+
+	for place, stop := range watchedRoots {
+		if !isRoot[place] {
+			stop()
+			delete(watchedRoots, place)
+		}
+	}
+}
+
+func SubscribeTemplateEvents() (chan TemplateEvent, func()) {
+	ch := make(chan TemplateEvent, 8)
+
+	templateSubscribersMu.Lock()
+	templateSubscribers[ch] = struct{}{}
+	templateSubscribersMu.Unlock()
+
+	return ch, func() {
+		templateSubscribersMu.Lock()
+		delete(templateSubscribers, ch)
+		templateSubscribersMu.Unlock()
+	}
+}
+
+func publishTemplateEvent(event TemplateEvent) {
+	templateSubscribersMu.Lock()
+	defer templateSubscribersMu.Unlock()
+
+	for ch := range templateSubscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// refreshTemplates re-reads only the changed templates and replaces the cache,
+// returns places of the changed templates or folders
+func refreshTemplates(changes []templateChange) []string {
+	folders := make([]string, 0)
+	isTemplate := make(map[string]bool)
+	for _, change := range changes {
+		root, ok := getPlaceRoot(change.place)
+		if !ok || isLibFolder(change.place) {
+			continue
+		}
+		folder, ok := getChangedFolder(root.place, change)
+		if _, exists := isTemplate[folder]; !exists {
+			folders = append(folders, folder)
+		}
+		isTemplate[folder] = ok
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+
+	places := make([]string, 0, len(folders))
+	var templates []Template
+	if TEMPLATES_CACHE != nil {
+		templates = append([]Template{}, TEMPLATES_CACHE...)
+	}
+
+	for _, folder := range folders {
+		relPlace, err := getRelPlace(folder)
+		if err != nil {
+			continue
+		}
+		places = append(places, relPlace)
+		if templates == nil {
+			continue
+		}
+
+		if isTemplate[folder] {
+			templates = replaceTemplate(templates, folder, relPlace)
+		} else {
+			templates = replaceTemplateFolder(templates, folder, relPlace)
+		}
+	}
+
+	if templates != nil {
+		TEMPLATES_CACHE = templates
+	}
+
+	return places
+}
+
+// getChangedFolder returns the template folder that contains the changed file
+// or the changed folder itself when it is not inside a template
+func getChangedFolder(root string, change templateChange) (string, bool) {
+	folder := change.place
+	if !change.isDir {
+		folder = filepath.Dir(folder)
+	}
+
+	for place := folder; place == root || strings.HasPrefix(place, root+string(filepath.Separator)); place = filepath.Dir(place) {
+		if _, err := os.Stat(filepath.Join(place, TEMPALTE_NAME)); err == nil {
+			return place, true
+		}
+		if place == root {
+			break
+		}
+	}
+	return folder, false
+}
+
+func replaceTemplate(templates []Template, folder string, relPlace string) []Template {
+	template, err := readTemplate(folder, false)
+	if err != nil {
+		log.Printf("Read template '%s' error: %v\n", folder, err)
+	}
+
+	for i := range templates {
+		if templates[i].Place == relPlace {
+			if template == nil {
+				return append(templates[:i], templates[i+1:]...)
+			}
+			templates[i] = *template
+			return templates
+		}
+	}
+	if template != nil {
+		templates = append(templates, *template)
+	}
+	return templates
+}
+
+func replaceTemplateFolder(templates []Template, folder string, relPlace string) []Template {
+	result := make([]Template, 0, len(templates))
+	for _, template := range templates {
+		if relPlace == "." || template.Place == relPlace || strings.HasPrefix(template.Place, relPlace+"/") {
+			continue
+		}
+		result = append(result, template)
+	}
+
+	if _, err := os.Stat(folder); err == nil {
+		result = append(result, readTemplateFolder(folder)...)
+	}
+	// the default root contains other roots in its places
+	if relPlace == "." {
+		for _, root := range getTemplateRoots()[1:] {
+			result = append(result, readTemplateFolder(root.place)...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+//go:build linux
+
+package taskQueue
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB
+
+type inotifyWatcher struct {
+	fd        int
+	wds       map[int]string
+	paths     map[string]int
+	rootWd    int
+	isStopped bool
+	mu        sync.Mutex
+}
+
+// watchTemplates watches the root and its subfolders, the returned func stops watching
+func watchTemplates(root string, changes chan<- templateChange) (func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &inotifyWatcher{
+		fd:    fd,
+		wds:   make(map[int]string),
+		paths: make(map[string]int),
+	}
+	if err := w.addRecursive(root); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	w.rootWd = w.paths[root]
+
+	go w.read(root, changes)
+
+	return w.stop, nil
+}
+
+// stop removes the watch of the root, the blocked read wakes up on IN_IGNORED and closes the descriptor
+func (s *inotifyWatcher) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isStopped = true
+	unix.InotifyRmWatch(s.fd, uint32(s.rootWd))
+}
+
+func (s *inotifyWatcher) addRecursive(root string) error {
+	return filepath.WalkDir(root, func(place string, entity os.DirEntry, err error) error {
+		if err != nil {
+			if place == root {
+				return err
+			}
+			return nil
+		}
+		if !entity.IsDir() {
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(s.fd, place, inotifyMask)
+		if err != nil {
+			log.Println("Add template watch error", place, err)
+			return nil
+		}
+		s.wds[wd] = place
+		s.paths[place] = wd
+		return nil
+	})
+}
+
+func (s *inotifyWatcher) removeRecursive(root string) {
+	for place, wd := range s.paths {
+		if place == root || strings.HasPrefix(place, root+string(filepath.Separator)) {
+			unix.InotifyRmWatch(s.fd, uint32(wd))
+			delete(s.paths, place)
+			delete(s.wds, wd)
+		}
+	}
+}
+
+func (s *inotifyWatcher) read(root string, changes chan<- templateChange) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := unix.Read(s.fd, buf)
+		s.mu.Lock()
+		isStopped := s.isStopped
+		s.mu.Unlock()
+		if isStopped {
+			unix.Close(s.fd)
+			return
+		}
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			log.Println("Read template events error", err)
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				changes <- templateChange{place: root, isDir: true}
+				continue
+			}
+
+			folder, ok := s.wds[int(event.Wd)]
+			if !ok {
+				continue
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(s.wds, int(event.Wd))
+				delete(s.paths, folder)
+				continue
+			}
+
+			place := folder
+			if name := string(bytes.TrimRight(nameBytes, "\x00")); name != "" {
+				place = filepath.Join(folder, name)
+			}
+
+			isDir := event.Mask&unix.IN_ISDIR != 0
+			if isDir {
+				if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					s.addRecursive(place)
+				}
+				if event.Mask&unix.IN_MOVED_FROM != 0 {
+					s.removeRecursive(place)
+				}
+			}
+
+			changes <- templateChange{place: place, isDir: isDir}
+		}
+	}
+}
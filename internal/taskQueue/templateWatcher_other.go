@@ -0,0 +1,49 @@
+//go:build !linux
+
+package taskQueue
+
+import (
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const TemplatesPollInterval = 2 * time.Second
+
+// watchTemplates polls modification times of the templates folder, any change reloads all templates
+func watchTemplates(root string, changes chan<- templateChange) (func(), error) {
+	stop := make(chan struct{})
+	go func() {
+		prev := getTemplatesSignature(root)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(TemplatesPollInterval):
+			}
+			current := getTemplatesSignature(root)
+			if current != prev {
+				prev = current
+				changes <- templateChange{place: root, isDir: true}
+			}
+		}
+	}()
+	return func() { close(stop) }, nil
+}
+
+func getTemplatesSignature(root string) uint64 {
+	hash := fnv.New64a()
+	filepath.WalkDir(root, func(place string, entity fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		hash.Write([]byte(place))
+		if info, err := entity.Info(); err == nil {
+			hash.Write([]byte(strconv.FormatInt(info.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(info.Size(), 10)))
+		}
+		return nil
+	})
+	return hash.Sum64()
+}
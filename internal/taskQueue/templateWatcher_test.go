@@ -0,0 +1,32 @@
+package taskQueue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetChangedFolder(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "tpl")
+	for _, place := range []string{filepath.Join(root, "a", "b"), filepath.Join(dir, "tpl2", "c")} {
+		if err := os.MkdirAll(place, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(filepath.Dir(place), TEMPALTE_NAME), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	folder, ok := getChangedFolder(root, templateChange{place: filepath.Join(root, "a", "b", "command.sh")})
+	if !ok || folder != filepath.Join(root, "a") {
+		t.Errorf("expected the template folder, got %v %v", folder, ok)
+	}
+
+	// a sibling folder which shares the name prefix of the root is not inside the root
+	place := filepath.Join(dir, "tpl2", "c")
+	folder, ok = getChangedFolder(root, templateChange{place: place, isDir: true})
+	if ok || folder != place {
+		t.Errorf("expected the changed folder outside of the root, got %v %v", folder, ok)
+	}
+}
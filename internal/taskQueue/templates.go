@@ -4,22 +4,35 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"goTaskQueue/assets"
 	"goTaskQueue/internal/cfg"
 	"goTaskQueue/internal/utils"
 	"log"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/natefinch/atomic"
 )
 
 type TemplateVariable struct {
-	Name         string `json:"name"`
-	Value        string `json:"value"`
-	DefaultValue string `json:"defaultValue"`
+	Name         string   `json:"name"`
+	Value        string   `json:"value"`
+	DefaultValue string   `json:"defaultValue"`
+	Type         string   `json:"type,omitempty"`
+	Options      []string `json:"options,omitempty"`
+	IsRequired   bool     `json:"isRequired,omitempty"`
+	Pattern      string   `json:"pattern,omitempty"`
+	Min          *float64 `json:"min,omitempty"`
+	Max          *float64 `json:"max,omitempty"`
+	Substitution string   `json:"substitution,omitempty"`
+
+	OptionsCommand string `json:"optionsCommand,omitempty"`
+	OptionsTimeout int64  `json:"optionsTimeout,omitempty"`
+	OptionsTtl     int64  `json:"optionsTtl,omitempty"`
 }
 
 type Template struct {
@@ -29,6 +42,11 @@ type Template struct {
 	Name      string             `json:"name"`
 	Id        string             `json:"id"`
 	Variables []TemplateVariable `json:"variables"`
+	Schedules []TemplateSchedule `json:"schedules,omitempty"`
+	Extends   string             `json:"extends,omitempty"`
+
+	IsReadOnly bool `json:"isReadOnly,omitempty"`
+	chain      []string
 
 	NewTaskBase
 }
@@ -36,6 +54,117 @@ type Template struct {
 const TEMPALTE_NAME = "template.json"
 const COMMAND_NAME = "command.sh"
 
+func (s *Template) GetTaskBase() TaskBase {
+	return TaskBase{
+		Command:          s.Command,
+		TemplatePlace:    s.Place,
+		TemplateId:       s.Id,
+		TemplateRevision: s.getRevision(),
+		TemplateChain:    s.chain,
+		NewTaskBase:      s.NewTaskBase,
+	}
+}
+
+// getRevision returns the revision of the template, a template changed outside of the API is recorded first,
+// templates are not hashed on read, only when a task is created from them
+func (s *Template) getRevision() string {
+	if s.Place == "" {
+		return ""
+	}
+
+	place, err := getPlace(s.Place)
+	if err != nil {
+		return ""
+	}
+	revision, err := ensureRevision(place)
+	if err != nil {
+		log.Println("Ensure template revision error", s.Place, err)
+	}
+	return revision
+}
+
+// ApplyTemplateVariables expands includes of the command and replaces variables,
+// the template is expected to be rendered by RenderTemplate. Invalid variables are
+// replaced as is and reported by *VariablesError after the task base is rendered
+func ApplyTemplateVariables(config *cfg.Config, taskBase *TaskBase, template *Template, values map[string]string) error {
+	command, err := expandIncludes(taskBase.Command, template.Place)
+	if err != nil {
+		return err
+	}
+	taskBase.Command = command
+
+	variables := withOptions(config, template)
+	values, valuesErr := GetVariableValues(variables, values)
+	taskBase.Variables = values
+
+	isShell := taskBase.Interpreter.isShell()
+	for _, variable := range variables {
+		if err := variable.checkInterpreter(taskBase.Interpreter); err != nil {
+			return fmt.Errorf("variable %v: %w", variable.Value, err)
+		}
+
+		old := fmt.Sprintf("{%v}", variable.Value)
+		value := values[variable.Value]
+		substitution := variable.Substitution
+		if substitution == "" && !isShell {
+			substitution = SUBSTITUTION_ENV
+		}
+		name := getVariableEnvName(variable.Value)
+		command := taskBase.Command
+		switch substitution {
+		case SUBSTITUTION_RAW:
+			command = strings.ReplaceAll(command, old, value)
+		case SUBSTITUTION_ENV:
+			setVariableEnv(taskBase, name, value)
+			if isShell {
+				command, err = replaceQuoted(command, old, func(quote byte) string {
+					return quoteEnv(name, quote)
+				})
+			} else {
+				// other languages read the value by the name of the env variable
+				command = strings.ReplaceAll(command, old, name)
+			}
+		default:
+			// values are not inlined into here-documents, the body refers to the env variable
+			isHereDoc := false
+			command, err = replaceQuoted(command, old, func(quote byte) string {
+				if quote == QUOTE_HEREDOC {
+					isHereDoc = true
+					return quoteEnv(name, quote)
+				}
+				return quoteValue(value, quote)
+			})
+			if isHereDoc {
+				setVariableEnv(taskBase, name, value)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("variable %v: %w", variable.Value, err)
+		}
+		taskBase.Command = command
+	}
+	taskBase.Label = replaceVariables(taskBase.Label, variables, values)
+	taskBase.WorkingDir = replaceVariables(taskBase.WorkingDir, variables, values)
+	return valuesErr
+}
+
+func setVariableEnv(taskBase *TaskBase, name string, value string) {
+	env := make(map[string]string, len(taskBase.Env)+1)
+	for key, v := range taskBase.Env {
+		env[key] = v
+	}
+	env[name] = value
+	taskBase.Env = env
+}
+
+// replaceVariables replaces variables in the text as is, for fields which are not run by a shell
+func replaceVariables(text string, variables []TemplateVariable, values map[string]string) string {
+	for _, variable := range variables {
+		text = strings.ReplaceAll(text, fmt.Sprintf("{%v}", variable.Value), values[variable.Value])
+	}
+	return text
+}
+
 func readTemplateFolder(place string) []Template {
 	templates := make([]Template, 0)
 
@@ -48,7 +177,7 @@ func readTemplateFolder(place string) []Template {
 	for i := 0; i < len(dir); i++ {
 		entity := dir[i]
 		subPlace := filepath.Join(place, entity.Name())
-		if !entity.IsDir() {
+		if !entity.IsDir() || isForeignFolder(subPlace) || isLibFolder(subPlace) {
 			continue
 		}
 		template, err := readTemplate(subPlace, true)
@@ -95,27 +224,57 @@ func readTemplate(place string, mayBeNull bool) (*Template, error) {
 		return nil, err
 	}
 
-	command, err := os.ReadFile(filepath.Join(place, COMMAND_NAME))
+	commandName := getCommandName(json.Interpreter)
+	command, err := os.ReadFile(filepath.Join(place, commandName))
 	if err != nil {
 		return nil, err
 	}
 
 	json.Command = string(command)
 	json.Place = relPlace
+	json.IsReadOnly = isReadOnlyPlace(place)
 	return json, nil
 }
 
-func WriteTemplate(template Template, isNew bool) error {
+func WriteTemplate(template Template, isNew bool, comment string) error {
 	relPlace := template.Place
 	command := template.Command
 
-	place, err := getPlace(relPlace)
+	place, err := getWritablePlace(relPlace)
 	if err != nil {
 		return err
 	}
 
 	template.Place = ""
 	template.Command = ""
+	template.IsReadOnly = false
+
+	schedules := make([]TemplateSchedule, 0, len(template.Schedules))
+	for _, schedule := range template.Schedules {
+		if _, err := schedule.getNext(time.Now()); err != nil {
+			return err
+		}
+		schedule.NextRunAt = nil
+		schedule.IsPaused = false
+		schedules = append(schedules, schedule)
+	}
+	template.Schedules = schedules
+
+	if err := validateTemplateVariables(template.Variables, template.Interpreter); err != nil {
+		return err
+	}
+
+	if template.Extends != "" {
+		canonicalPlace, err := getRelPlace(place)
+		if err != nil {
+			return err
+		}
+		child := &Template{Place: canonicalPlace, Extends: template.Extends}
+		child.Interpreter = template.Interpreter
+		if _, err := getTemplateChain(child); err != nil {
+			return err
+		}
+	}
 
 	json, err := json.Marshal(template)
 	if err != nil {
@@ -135,6 +294,10 @@ func WriteTemplate(template Template, isNew bool) error {
 		return err
 	}
 
+	if _, err := ensureRevision(place); err != nil {
+		return err
+	}
+
 	err = os.MkdirAll(place, 0700)
 	if err != nil {
 		return err
@@ -145,18 +308,26 @@ func WriteTemplate(template Template, isNew bool) error {
 		return err
 	}
 
-	err = atomic.WriteFile(filepath.Join(place, COMMAND_NAME), strings.NewReader(command))
+	commandName := getCommandName(template.Interpreter)
+	err = atomic.WriteFile(filepath.Join(place, commandName), strings.NewReader(command))
 	if err != nil {
 		return err
 	}
 
+	removeCommandFiles(place, commandName)
+
+	addRevision(place, TemplateRevision{
+		Action:  REVISION_WRITE,
+		Comment: comment,
+	})
+
 	FlushTemplateCache()
 
 	return nil
 }
 
-func RemoveTemplate(relPlace string) error {
-	place, err := getPlace(relPlace)
+func RemoveTemplate(relPlace string, comment string) error {
+	place, err := getWritablePlace(relPlace)
 	if err != nil {
 		return err
 	}
@@ -166,6 +337,14 @@ func RemoveTemplate(relPlace string) error {
 		return err
 	}
 
+	err = recordRevision(place, TemplateRevision{
+		Action:  REVISION_REMOVE,
+		Comment: comment,
+	})
+	if err != nil {
+		return err
+	}
+
 	err = os.RemoveAll(place)
 
 	if err == nil {
@@ -177,13 +356,13 @@ func RemoveTemplate(relPlace string) error {
 	return err
 }
 
-func MoveTemplate(relFrom string, relTo string) error {
-	from, err := getPlace(relFrom)
+func MoveTemplate(relFrom string, relTo string, comment string) error {
+	from, err := getWritablePlace(relFrom)
 	if err != nil {
 		return err
 	}
 
-	to, err := getPlace(relTo)
+	to, err := getWritablePlace(relTo)
 	if err != nil {
 		return err
 	}
@@ -202,29 +381,16 @@ func MoveTemplate(relFrom string, relTo string) error {
 		return errors.New("to_place_not_empty")
 	}
 
-	err = os.MkdirAll(filepath.Dir(to), 0700)
-	if err != nil {
-		return err
-	}
-
-	err = os.Rename(from, to)
-
-	if err == nil {
-		cleanTemplates()
-	}
-
-	FlushTemplateCache()
-
-	return err
+	return moveTemplates(from, to, []string{from}, comment)
 }
 
 func MoveTemplateFolder(relFrom string, relTo string) error {
-	from, err := getPlace(relFrom)
+	from, err := getWritablePlace(relFrom)
 	if err != nil {
 		return err
 	}
 
-	to, err := getPlace(relTo)
+	to, err := getWritablePlace(relTo)
 	if err != nil {
 		return err
 	}
@@ -238,7 +404,24 @@ func MoveTemplateFolder(relFrom string, relTo string) error {
 		return errors.New("to_place_not_empty")
 	}
 
-	err = os.MkdirAll(filepath.Dir(to), 0700)
+	return moveTemplates(from, to, findTemplateFolders(from), "")
+}
+
+// moveTemplates renames the folder and records a move revision for every template inside it
+func moveTemplates(from string, to string, places []string, comment string) error {
+	prevPlaces := make([]string, 0, len(places))
+	for _, place := range places {
+		if _, err := ensureRevision(place); err != nil {
+			return err
+		}
+		relPlace, err := getRelPlace(place)
+		if err != nil {
+			return err
+		}
+		prevPlaces = append(prevPlaces, relPlace)
+	}
+
+	err := os.MkdirAll(filepath.Dir(to), 0700)
 	if err != nil {
 		return err
 	}
@@ -246,6 +429,14 @@ func MoveTemplateFolder(relFrom string, relTo string) error {
 	err = os.Rename(from, to)
 
 	if err == nil {
+		for i, place := range places {
+			addRevision(filepath.Join(to, strings.TrimPrefix(place, from)), TemplateRevision{
+				Action:    REVISION_MOVE,
+				PrevPlace: prevPlaces[i],
+				Comment:   comment,
+			})
+		}
+
 		cleanTemplates()
 	}
 
@@ -254,11 +445,46 @@ func MoveTemplateFolder(relFrom string, relTo string) error {
 	return err
 }
 
-func cleanTemplates() {
-	root := getTemplatesPath()
+func findTemplateFolders(folder string) []string {
+	places := make([]string, 0)
+	filepath.WalkDir(folder, func(place string, entity os.DirEntry, err error) error {
+		if err != nil || !entity.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(place, TEMPALTE_NAME)); err == nil {
+			places = append(places, place)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return places
+}
+
+// removeCommandFiles removes scripts left after the interpreter of the template is changed
+func removeCommandFiles(place string, keepName string) {
+	names, err := filepath.Glob(filepath.Join(place, "command*"))
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		base := filepath.Base(name)
+		if base == keepName || strings.TrimSuffix(base, filepath.Ext(base)) != "command" {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			log.Println("Remove command file error", name, err)
+		}
+	}
+}
 
-	if err := cleanEmptyFolders(root); err != nil {
-		log.Println("Clean templates error", err)
+func cleanTemplates() {
+	for _, root := range getTemplateRoots() {
+		if root.isReadOnly {
+			continue
+		}
+		if err := cleanEmptyFolders(root.place); err != nil {
+			log.Println("Clean templates error", err)
+		}
 	}
 }
 
@@ -297,13 +523,26 @@ func cleanEmptyFolders(place string) error {
 	return nil
 }
 
+// TEMPLATES_CACHE is replaced as a whole on every change, so readers may keep the returned slice
 var TEMPLATES_CACHE []Template
+var templatesMu sync.RWMutex
 
 func GetTemplates() []Template {
-	root := getTemplatesPath()
+	templatesMu.RLock()
+	templates := TEMPLATES_CACHE
+	templatesMu.RUnlock()
+	if templates != nil {
+		return templates
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
 
 	if TEMPLATES_CACHE == nil {
-		templates := readTemplateFolder(root)
+		templates := make([]Template, 0)
+		for _, root := range getTemplateRoots() {
+			templates = append(templates, readTemplateFolder(root.place)...)
+		}
 		TEMPLATES_CACHE = templates
 	}
 
@@ -311,7 +550,11 @@ func GetTemplates() []Template {
 }
 
 func FlushTemplateCache() {
+	templatesMu.Lock()
 	TEMPLATES_CACHE = nil
+	templatesMu.Unlock()
+
+	flushOptionsCache()
 }
 
 func GetTemplate(id string) (*Template, error) {
@@ -326,13 +569,30 @@ func GetTemplate(id string) (*Template, error) {
 }
 
 func getRelPlace(place string) (string, error) {
-	root := getTemplatesPath()
+	root, ok := getPlaceRoot(place)
+	if !ok {
+		return "", errors.New("place_is_outside_of_templates")
+	}
 
-	relPath, err := filepath.Rel(root, place)
+	relPath, err := filepath.Rel(root.place, place)
 	if err != nil {
 		return "", err
 	}
 	relPath = filepath.ToSlash(relPath)
+
+	if root.prefix != "" {
+		if relPath == "." {
+			return root.prefix, nil
+		}
+		return root.prefix + "/" + relPath, nil
+	}
+
+	first, _, _ := strings.Cut(relPath, "/")
+	for _, r := range getTemplateRoots()[1:] {
+		if first == r.prefix {
+			return "", errors.New("template_place_is_shadowed")
+		}
+	}
 	return relPath, err
 }
 
@@ -341,11 +601,8 @@ func GetPlace(relPlace string) (string, error) {
 }
 
 func getPlace(relPlace string) (string, error) {
-	if filepath.Separator != '/' && strings.ContainsRune(relPlace, filepath.Separator) {
-		return "", errors.New("invalid character in file path")
-	}
-	root := getTemplatesPath()
-	return filepath.Join(root, filepath.FromSlash(path.Clean("/"+relPlace))), nil
+	_, place, err := resolvePlace(relPlace)
+	return place, err
 }
 
 func GetTemplatesPath() string {
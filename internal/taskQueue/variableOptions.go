@@ -0,0 +1,132 @@
+package taskQueue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"goTaskQueue/internal/cfg"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultOptionsTimeout = 10
+const DefaultOptionsTtl = 60
+
+type variableOptionsCache struct {
+	options   []string
+	expiresAt time.Time
+}
+
+var OPTIONS_CACHE = make(map[string]variableOptionsCache)
+var optionsCacheMu sync.Mutex
+
+// GetVariableOptions returns options of enum variables of the template that are computed by a command
+func GetVariableOptions(config *cfg.Config, template *Template) (map[string][]string, error) {
+	result := make(map[string][]string)
+	for _, variable := range template.Variables {
+		if variable.OptionsCommand == "" {
+			continue
+		}
+		options, err := variable.getOptions(config, template)
+		if err != nil {
+			return nil, err
+		}
+		result[variable.Value] = options
+	}
+	return result, nil
+}
+
+// withOptions returns variables where options computed by a command are filled in
+func withOptions(config *cfg.Config, template *Template) []TemplateVariable {
+	variables := make([]TemplateVariable, 0, len(template.Variables))
+	for _, variable := range template.Variables {
+		if variable.OptionsCommand != "" {
+			options, err := variable.getOptions(config, template)
+			if err != nil {
+				log.Println("Get variable options error", template.Place, variable.Value, err)
+			}
+			variable.Options = options
+		}
+		variables = append(variables, variable)
+	}
+	return variables
+}
+
+func (s *TemplateVariable) getOptions(config *cfg.Config, template *Template) ([]string, error) {
+	key := template.Place + "|" + s.Value + "|" + s.OptionsCommand
+
+	optionsCacheMu.Lock()
+	cache, ok := OPTIONS_CACHE[key]
+	optionsCacheMu.Unlock()
+	if ok && time.Now().Before(cache.expiresAt) {
+		return cache.options, nil
+	}
+
+	options, err := s.runOptionsCommand(config, template)
+	if err != nil {
+		return nil, err
+	}
+
+	optionsCacheMu.Lock()
+	OPTIONS_CACHE[key] = variableOptionsCache{
+		options:   options,
+		expiresAt: time.Now().Add(getSeconds(s.OptionsTtl, DefaultOptionsTtl)),
+	}
+	optionsCacheMu.Unlock()
+
+	return options, nil
+}
+
+func (s *TemplateVariable) runOptionsCommand(config *cfg.Config, template *Template) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), getSeconds(s.OptionsTimeout, DefaultOptionsTimeout))
+	defer cancel()
+
+	// the command runs in the same environment as tasks of the template
+	task := &Task{TaskBase: template.GetTaskBase()}
+	env, err := task.getEnvVariables(config)
+	if err != nil {
+		return nil, err
+	}
+
+	runAs := config.Run
+	args := append(append([]string{}, runAs[1:]...), s.OptionsCommand)
+	process := exec.CommandContext(ctx, runAs[0], args...)
+	process.Env = env
+	process.Dir = task.getWorkingDir()
+	// children of the shell may keep the output open after the timeout
+	process.WaitDelay = time.Second
+	if err := task.setCredential(process); err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	process.Stderr = &stderr
+	output, err := process.Output()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.New("options_command_timeout")
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, errors.New(message)
+		}
+		return nil, err
+	}
+
+	options := make([]string, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			options = append(options, line)
+		}
+	}
+	return options, nil
+}
+
+func flushOptionsCache() {
+	optionsCacheMu.Lock()
+	OPTIONS_CACHE = make(map[string]variableOptionsCache)
+	optionsCacheMu.Unlock()
+}
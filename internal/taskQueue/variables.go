@@ -0,0 +1,398 @@
+package taskQueue
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const VARIABLE_STRING = "string"
+const VARIABLE_INTEGER = "integer"
+const VARIABLE_BOOLEAN = "boolean"
+const VARIABLE_ENUM = "enum"
+const VARIABLE_PATH = "path"
+const VARIABLE_MULTILINE = "multiline"
+
+const SUBSTITUTION_QUOTED = "quoted"
+const SUBSTITUTION_ENV = "env"
+const SUBSTITUTION_RAW = "raw"
+
+const VariableEnvPrefix = "TQ_VAR_"
+
+type VariableError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+type VariablesError struct {
+	Errors []VariableError `json:"errors"`
+}
+
+func (e *VariablesError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		parts = append(parts, fieldErr.Name+": "+fieldErr.Error)
+	}
+	return "invalid variables: " + strings.Join(parts, "; ")
+}
+
+// validate checks the variable definition, it is called when the template is written
+func (s *TemplateVariable) validate() error {
+	switch s.Type {
+	case "", VARIABLE_STRING, VARIABLE_INTEGER, VARIABLE_BOOLEAN, VARIABLE_PATH, VARIABLE_MULTILINE:
+	case VARIABLE_ENUM:
+		if len(s.Options) == 0 && s.OptionsCommand == "" {
+			return errors.New("enum_without_options")
+		}
+	default:
+		return fmt.Errorf("unknown variable type %v", s.Type)
+	}
+
+	switch s.Substitution {
+	case "", SUBSTITUTION_QUOTED, SUBSTITUTION_ENV, SUBSTITUTION_RAW:
+	default:
+		return fmt.Errorf("unknown substitution %v", s.Substitution)
+	}
+
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return err
+		}
+	}
+
+	if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+		return errors.New("min_greater_than_max")
+	}
+
+	return nil
+}
+
+// check validates the value and returns it in the normalized form
+func (s *TemplateVariable) check(value string) (string, error) {
+	if value == "" {
+		if s.IsRequired {
+			return "", errors.New("required")
+		}
+		return value, nil
+	}
+
+	switch s.Type {
+	case VARIABLE_INTEGER:
+		number, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", errors.New("must be an integer")
+		}
+		if err := s.checkRange(float64(number), "value"); err != nil {
+			return "", err
+		}
+		value = strconv.FormatInt(number, 10)
+	case VARIABLE_BOOLEAN:
+		flag, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", errors.New("must be a boolean")
+		}
+		value = strconv.FormatBool(flag)
+	case VARIABLE_ENUM:
+		if len(s.Options) == 0 {
+			return "", errors.New("options are not available")
+		}
+		if !slices.Contains(s.Options, value) {
+			return "", fmt.Errorf("must be one of %v", strings.Join(s.Options, ", "))
+		}
+	case VARIABLE_MULTILINE:
+		if err := s.checkRange(float64(utf8.RuneCountInString(value)), "length"); err != nil {
+			return "", err
+		}
+	default:
+		// untyped variables keep accepting any value as before types were added
+		if s.Type == VARIABLE_STRING && strings.ContainsAny(value, "\r\n") {
+			return "", errors.New("must be a single line")
+		}
+		if s.Type == VARIABLE_PATH && strings.ContainsRune(value, 0) {
+			return "", errors.New("invalid path")
+		}
+		if err := s.checkRange(float64(utf8.RuneCountInString(value)), "length"); err != nil {
+			return "", err
+		}
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(value) {
+			return "", fmt.Errorf("must match %v", s.Pattern)
+		}
+	}
+
+	return value, nil
+}
+
+func (s *TemplateVariable) checkRange(value float64, subject string) error {
+	if s.Min != nil && value < *s.Min {
+		return fmt.Errorf("%v must be at least %v", subject, *s.Min)
+	}
+	if s.Max != nil && value > *s.Max {
+		return fmt.Errorf("%v must be at most %v", subject, *s.Max)
+	}
+	return nil
+}
+
+// GetVariableValues returns values of all variables, missing values are taken from defaults,
+// invalid values are kept as is next to the *VariablesError, so the task can still be previewed
+func GetVariableValues(variables []TemplateVariable, values map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(variables))
+	fieldErrors := make([]VariableError, 0)
+
+	for _, variable := range variables {
+		value, ok := values[variable.Value]
+		if !ok {
+			value = variable.DefaultValue
+		}
+
+		checked, err := variable.check(value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, VariableError{Name: variable.Value, Error: err.Error()})
+			result[variable.Value] = value
+			continue
+		}
+		result[variable.Value] = checked
+	}
+
+	if len(fieldErrors) > 0 {
+		return result, &VariablesError{Errors: fieldErrors}
+	}
+	return result, nil
+}
+
+func validateTemplateVariables(variables []TemplateVariable, interpreter *TaskInterpreter) error {
+	for _, variable := range variables {
+		if err := variable.validate(); err != nil {
+			return fmt.Errorf("variable %v: %w", variable.Value, err)
+		}
+		if err := variable.checkInterpreter(interpreter); err != nil {
+			return fmt.Errorf("variable %v: %w", variable.Value, err)
+		}
+	}
+	return nil
+}
+
+// checkInterpreter rejects shell quoting for scripts of other languages
+func (s *TemplateVariable) checkInterpreter(interpreter *TaskInterpreter) error {
+	if s.Substitution == SUBSTITUTION_QUOTED && !interpreter.isShell() {
+		return errors.New("quoted substitution requires a shell interpreter")
+	}
+	return nil
+}
+
+// shellQuote returns the value as a single shell word
+func shellQuote(value string) string {
+	if value == "" {
+		return "''"
+	}
+	isSafe := true
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_@%+=:,./-", r)) {
+			isSafe = false
+			break
+		}
+	}
+	if isSafe {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// shell contexts of a placeholder
+const QUOTE_NONE = 0
+const QUOTE_SINGLE = '\''
+const QUOTE_DOUBLE = '"'
+const QUOTE_HEREDOC = '<'
+
+var errQuoteContext = errors.New("placeholder is in an unsupported shell context")
+
+type quoteEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// replaceQuoted replaces the placeholder with the text returned by replace for the shell context
+// of the placeholder, quotes around the placeholder in older templates ("{url}") are replaced too.
+// Placeholders in comments are kept, contexts which can't be quoted safely are rejected
+func replaceQuoted(command string, old string, replace func(quote byte) string) (string, error) {
+	if !strings.Contains(command, old) {
+		return command, nil
+	}
+	file, err := syntax.NewParser(syntax.KeepComments(true)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return "", fmt.Errorf("parse command error: %w", err)
+	}
+
+	edits := make([]quoteEdit, 0)
+	comments := 0
+	hereDocs := make(map[*syntax.Word]bool)
+	stack := make([]syntax.Node, 0)
+	addEdits := func(start, end int, quote byte) {
+		for i := strings.Index(command[start:end], old); i >= 0; i = strings.Index(command[start:end], old) {
+			edits = append(edits, quoteEdit{start: start + i, end: start + i + len(old), text: replace(quote)})
+			start += i + len(old)
+		}
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		if err != nil {
+			return false
+		}
+
+		// spans of statements don't include here-document bodies, only leaves are checked
+		start, end := int(node.Pos().Offset()), int(node.End().Offset())
+		isFound := strings.Contains(command[start:end], old)
+
+		switch x := node.(type) {
+		case *syntax.Comment:
+			comments += strings.Count(command[start:end], old)
+		case *syntax.Redirect:
+			if x.Hdoc != nil {
+				// a quoted delimiter turns off expansions in the body, env references are not expanded
+				lit := x.Word.Lit()
+				hereDocs[x.Hdoc] = lit != "" && !strings.ContainsAny(lit, `\'"`)
+			}
+		case *syntax.SglQuoted:
+			if !isFound {
+				return false
+			}
+			if x.Dollar || !isPlainWordContext(stack) {
+				err = errQuoteContext
+			} else if x.Value == old {
+				edits = append(edits, quoteEdit{start: start, end: end, text: replace(QUOTE_NONE)})
+			} else {
+				addEdits(start+1, end-1, QUOTE_SINGLE)
+			}
+			return false
+		case *syntax.DblQuoted:
+			if lit, ok := getSinglePart(x.Parts); ok && !x.Dollar && lit.Value == old && isPlainWordContext(stack) {
+				edits = append(edits, quoteEdit{start: start, end: end, text: replace(QUOTE_NONE)})
+				return false
+			}
+		case *syntax.Lit:
+			if !isFound {
+				return false
+			}
+			switch parent := stack[len(stack)-1].(type) {
+			case *syntax.DblQuoted:
+				if isPlainWordContext(stack) {
+					addEdits(start, end, QUOTE_DOUBLE)
+				} else {
+					err = errQuoteContext
+				}
+			case *syntax.Word:
+				if isExpanded, ok := hereDocs[parent]; ok && isExpanded {
+					addEdits(start, end, QUOTE_HEREDOC)
+				} else if ok {
+					err = errors.New("placeholder in a quoted here-document is not expanded")
+				} else if isPlainWordContext(stack) {
+					addEdits(start, end, QUOTE_NONE)
+				} else {
+					err = errQuoteContext
+				}
+			default:
+				err = errQuoteContext
+			}
+			return false
+		}
+
+		stack = append(stack, node)
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	// a placeholder split between nodes, like "${x:-{url}}", is not found in any of them
+	if len(edits)+comments != strings.Count(command, old) {
+		return "", errQuoteContext
+	}
+
+	slices.SortFunc(edits, func(a, b quoteEdit) int {
+		return a.start - b.start
+	})
+	var result strings.Builder
+	last := 0
+	for _, edit := range edits {
+		result.WriteString(command[last:edit.start])
+		result.WriteString(edit.text)
+		last = edit.end
+	}
+	result.WriteString(command[last:])
+	return result.String(), nil
+}
+
+// isPlainWordContext reports that the innermost command word is not a part of a parameter
+// expansion, an arithmetic expression or a backquoted command, quoting rules differ there
+func isPlainWordContext(stack []syntax.Node) bool {
+	isWord := true
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch x := stack[i].(type) {
+		case *syntax.Stmt:
+			isWord = false
+		case *syntax.ParamExp, *syntax.ArithmExp, *syntax.ArithmCmd, *syntax.LetClause, *syntax.CStyleLoop:
+			if isWord {
+				return false
+			}
+		case *syntax.CmdSubst:
+			if x.Backquotes {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func getSinglePart(parts []syntax.WordPart) (*syntax.Lit, bool) {
+	if len(parts) != 1 {
+		return nil, false
+	}
+	lit, ok := parts[0].(*syntax.Lit)
+	return lit, ok
+}
+
+// quoteValue returns the replacement of a quoted placeholder, inside quotes
+// the quote is closed and the value is inserted as a separate shell word
+func quoteValue(value string, quote byte) string {
+	switch quote {
+	case QUOTE_SINGLE, QUOTE_DOUBLE:
+		return string(quote) + shellQuote(value) + string(quote)
+	}
+	return shellQuote(value)
+}
+
+// quoteEnv returns the reference to the env variable of a placeholder
+func quoteEnv(name string, quote byte) string {
+	switch quote {
+	case QUOTE_SINGLE:
+		return `'"$` + name + `"'`
+	case QUOTE_DOUBLE, QUOTE_HEREDOC:
+		return "${" + name + "}"
+	}
+	return `"$` + name + `"`
+}
+
+func getVariableEnvName(name string) string {
+	return VariableEnvPrefix + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
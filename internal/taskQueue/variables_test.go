@@ -0,0 +1,159 @@
+package taskQueue
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestGetVariableValues(t *testing.T) {
+	min := 1.0
+	max := 10.0
+	variables := []TemplateVariable{
+		{Value: "name", IsRequired: true},
+		{Value: "count", Type: VARIABLE_INTEGER, DefaultValue: "3", Min: &min, Max: &max},
+		{Value: "flag", Type: VARIABLE_BOOLEAN},
+		{Value: "mode", Type: VARIABLE_ENUM, Options: []string{"fast", "slow"}, DefaultValue: "fast"},
+		{Value: "tag", Pattern: "^v[0-9]+$"},
+	}
+
+	values, err := GetVariableValues(variables, map[string]string{"name": "a", "flag": "1", "tag": "v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"name": "a", "count": "3", "flag": "true", "mode": "fast", "tag": "v2"}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%v: expected %q, got %q", key, value, values[key])
+		}
+	}
+
+	_, err = GetVariableValues(variables, map[string]string{"count": "11", "flag": "maybe", "mode": "other", "tag": "x"})
+	var variablesErr *VariablesError
+	if !errors.As(err, &variablesErr) {
+		t.Fatalf("expected variables error, got %v", err)
+	}
+	names := make([]string, 0)
+	for _, fieldErr := range variablesErr.Errors {
+		names = append(names, fieldErr.Name)
+	}
+	if len(names) != 5 {
+		t.Errorf("expected errors for all fields, got %v", variablesErr.Errors)
+	}
+}
+
+func TestGetVariableValuesLines(t *testing.T) {
+	variables := []TemplateVariable{
+		{Value: "text"},
+		{Value: "name", Type: VARIABLE_STRING},
+	}
+
+	values, err := GetVariableValues(variables, map[string]string{"text": "a\nb"})
+	if err != nil || values["text"] != "a\nb" {
+		t.Errorf("untyped variable must accept lines, got %q %v", values["text"], err)
+	}
+
+	if _, err := GetVariableValues(variables, map[string]string{"name": "a\r\nb"}); err == nil {
+		t.Error("expected single line error")
+	}
+}
+
+func TestValidateTemplateVariables(t *testing.T) {
+	min := 5.0
+	max := 1.0
+	invalid := []TemplateVariable{
+		{Value: "a", Type: "unknown"},
+		{Value: "b", Type: VARIABLE_ENUM},
+		{Value: "c", Pattern: "("},
+		{Value: "d", Min: &min, Max: &max},
+	}
+	for _, variable := range invalid {
+		if err := validateTemplateVariables([]TemplateVariable{variable}, nil); err == nil {
+			t.Errorf("%v: expected error", variable.Value)
+		}
+	}
+}
+
+func TestApplyTemplateVariablesSubstitution(t *testing.T) {
+	variables := []TemplateVariable{
+		{Value: "q"},
+		{Value: "old"},
+		{Value: "e", Substitution: SUBSTITUTION_ENV},
+		{Value: "r", Substitution: SUBSTITUTION_RAW},
+	}
+	taskBase := TaskBase{Command: `echo {q} "{old}" {e} {r}`}
+	taskBase.Label = "{q}"
+
+	values := map[string]string{"q": "it's; rm", "old": "a b", "e": "$HOME", "r": "$HOME"}
+	if err := ApplyTemplateVariables(nil, &taskBase, &Template{Variables: variables}, values); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `echo 'it'\''s; rm' 'a b' "$TQ_VAR_e" $HOME`
+	if taskBase.Command != expected {
+		t.Errorf("expected %q, got %q", expected, taskBase.Command)
+	}
+	if taskBase.Label != "it's; rm" {
+		t.Errorf("label is not raw: %q", taskBase.Label)
+	}
+	if taskBase.Env["TQ_VAR_e"] != "$HOME" {
+		t.Errorf("env is not set: %v", taskBase.Env)
+	}
+}
+
+func TestApplyTemplateVariablesQuoteContext(t *testing.T) {
+	value := "$(echo INJECTED) `echo INJECTED` \"a'b\" \\ !\nEOF\necho INJECTED"
+	command := strings.Join([]string{
+		`# it's a comment {v}`,
+		`printf '%s|' {v} "{v}" "pre {v} post" 'pre {v} post' "$(printf '%s' "{v}")"`,
+		`cat <<-EOF`,
+		`	{v}`,
+		`	EOF`,
+	}, "\n")
+	expected := strings.Repeat(value+"|", 2) + "pre " + value + " post|pre " + value + " post|" + value + "|" + value + "\n"
+
+	for _, substitution := range []string{SUBSTITUTION_QUOTED, SUBSTITUTION_ENV} {
+		taskBase := TaskBase{Command: command}
+		variables := []TemplateVariable{{Value: "v", Substitution: substitution}}
+		if err := ApplyTemplateVariables(nil, &taskBase, &Template{Variables: variables}, map[string]string{"v": value}); err != nil {
+			t.Fatal(err)
+		}
+
+		process := exec.Command("sh", "-c", taskBase.Command)
+		for key, v := range taskBase.Env {
+			process.Env = append(process.Env, key+"="+v)
+		}
+		output, err := process.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %v %s", substitution, err, output)
+		}
+		if string(output) != expected {
+			t.Errorf("%v: expected %q, got %q\n%v", substitution, expected, output, taskBase.Command)
+		}
+
+		for _, command := range []string{"cat <<'EOF'\n{v}\nEOF", `echo "${x:-{v}}"`, "echo `echo {v}`"} {
+			taskBase := TaskBase{Command: command}
+			if err := ApplyTemplateVariables(nil, &taskBase, &Template{Variables: variables}, map[string]string{"v": value}); err == nil {
+				t.Errorf("%v: expected context error for %q, got %q", substitution, command, taskBase.Command)
+			}
+		}
+	}
+}
+
+func TestApplyTemplateVariablesInterpreter(t *testing.T) {
+	taskBase := TaskBase{Command: `print(os.environ["{v}"])`}
+	taskBase.Interpreter = &TaskInterpreter{Command: []string{"python3"}}
+	variables := []TemplateVariable{{Value: "v"}}
+	if err := ApplyTemplateVariables(nil, &taskBase, &Template{Variables: variables}, map[string]string{"v": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if taskBase.Command != `print(os.environ["TQ_VAR_v"])` || taskBase.Env["TQ_VAR_v"] != "x" {
+		t.Errorf("unexpected substitution %q %v", taskBase.Command, taskBase.Env)
+	}
+
+	variables[0].Substitution = SUBSTITUTION_QUOTED
+	if err := ApplyTemplateVariables(nil, &taskBase, &Template{Variables: variables}, map[string]string{"v": "x"}); err == nil {
+		t.Error("expected quoted substitution error")
+	}
+}
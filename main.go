@@ -19,6 +19,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"runtime"
 	"strings"
@@ -32,6 +33,11 @@ import (
 var DEBUG_UI = os.Getenv("DEBUG_UI") == "1"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == taskQueue.ShimArg {
+		taskQueue.RunShim()
+		return
+	}
+
 	if _, err := mutex.CreateMutex("GoTaskQueue"); err != nil {
 		panic(err)
 	}
@@ -39,6 +45,9 @@ func main() {
 	var config = cfg.LoadConfig()
 
 	taskQueue.InitTemplates()
+	setTemplateRoots(&config)
+
+	go taskQueue.RunTemplateWatcher()
 
 	var powerControl = powerCtr.GetPowerControl()
 	var taskQueue = taskQueue.LoadQueue(&config)
@@ -59,6 +68,8 @@ func main() {
 
 			router := internal.NewRouter()
 
+			// long-lived event connections must not hold the power lock
+			handleTemplateEvents(router)
 			powerLock(router, powerControl)
 			handleWebsocket(router, taskQueue)
 			internal.HandleApi(router, taskQueue, memStorage, &config, callChan)
@@ -89,6 +100,7 @@ func main() {
 			switch v {
 			case "reload":
 				config = cfg.LoadConfig()
+				setTemplateRoots(&config)
 				init()
 			}
 		}
@@ -98,6 +110,10 @@ func main() {
 		taskQueue.RunOnBoot(&config)
 	}()
 
+	go taskQueue.RunSchedules()
+
+	go taskQueue.RunStats()
+
 	go func() {
 		for {
 			taskQueue.Cleanup(&config)
@@ -105,17 +121,32 @@ func main() {
 		}
 	}()
 
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		log.Println("Received signal", sig)
+		shutdown(taskQueue, &config)
+	}()
+
 	disableTrayIconPtr := flag.Bool("disableTrayIcon", false, "Disable tray icon")
 	flag.Parse()
 
 	if !*disableTrayIconPtr {
 		trayIcon.TrayIcon(&config, callChan)
+		shutdown(taskQueue, &config)
 	} else {
 		loopChan := make(chan string)
 		<-loopChan
 	}
 }
 
+func shutdown(queue *taskQueue.Queue, config *cfg.Config) {
+	log.Println("Shutdown...")
+	queue.Shutdown(config)
+	os.Exit(0)
+}
+
 func powerLock(router *internal.Router, powerControl *powerCtr.PowerControl) {
 	if powerControl == nil {
 		return
@@ -228,6 +259,41 @@ func handleWebsocket(router *internal.Router, queue *taskQueue.Queue) {
 	router.All("/ws", websocket.Handler(ws).ServeHTTP)
 }
 
+// setTemplateRoots is called where the queue variable shadows the taskQueue package
+func setTemplateRoots(config *cfg.Config) {
+	taskQueue.SetTemplateRoots(config.TemplateRoots)
+}
+
+func handleTemplateEvents(router *internal.Router) {
+	ws := func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		events, unsubscribe := taskQueue.SubscribeTemplateEvents()
+		defer unsubscribe()
+
+		closed := make(chan struct{})
+		go func() {
+			var data string
+			for websocket.Message.Receive(ws, &data) == nil {
+			}
+			close(closed)
+		}()
+
+		for {
+			select {
+			case event := <-events:
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}
+
+	router.All("/ws/templates", websocket.Handler(ws).ServeHTTP)
+}
+
 func handleWww(router *internal.Router, queue *taskQueue.Queue, memStorage *memstorage.MemStorage, config *cfg.Config) {
 	binTime := time.Now()
 	if binPath, err := os.Executable(); err == nil {
@@ -273,7 +339,7 @@ func handleWww(router *internal.Router, queue *taskQueue.Queue, memStorage *mems
 		if assetPath == "/index.html" {
 			mTime = time.Now()
 
-			templates := taskQueue.GetTemplates()
+			templates := queue.WithSchedules(taskQueue.GetTemplates())
 
 			store := RootStore{
 				Name:           config.Name,
@@ -32,6 +32,16 @@ const NotificationProvider: FC<NotificationProviderProps> = ({children}) => {
         message = 'Canceled';
         break;
       }
+      case TaskState.Skipped: {
+        color = 'warning';
+        message = 'Skipped';
+        break;
+      }
+      case TaskState.Timeout: {
+        color = 'error';
+        message = 'Timeout';
+        break;
+      }
       case TaskState.Error: {
         color = 'error';
         message = `Error: ${error}`;
@@ -1,4 +1,13 @@
-import React, {FC, SyntheticEvent, useCallback, useContext, useMemo, useRef, useState} from 'react';
+import React, {
+  FC,
+  SyntheticEvent,
+  useCallback,
+  useContext,
+  useEffect,
+  useMemo,
+  useRef,
+  useState,
+} from 'react';
 import {
   Box,
   Button,
@@ -17,6 +26,7 @@ import {RootStoreCtx} from '../RootStore/RootStoreCtx';
 import ActionButton from '../ActionButton/ActionButton';
 import {CommandFieldRef} from '../CommandField/CommandField';
 import CommandFieldAsync from '../CommandField/CommandFieldAsync';
+import {api} from '../../tools/api';
 
 export interface TemplateDialogProps {
   open: boolean;
@@ -62,34 +72,67 @@ const TemplateDialog: FC<TemplateDialogProps> = ({
   const refWriteLogs = useRef<HTMLInputElement>(null);
   const refSingleInstance = useRef<HTMLInputElement>(null);
   const refStartOnBoot = useRef<HTMLInputElement>(null);
+  const [dynamicOptions, setDynamicOptions] = useState<Record<string, string[]>>({});
   const refMap = useRef(new Map());
   variables.forEach(({value}) => {
     // eslint-disable-next-line react-hooks/rules-of-hooks
     refMap.current.set(value, useRef(null));
   });
 
-  const variableInputs = useMemo(() => {
-    return variables.map(({name, value, defaultValue}, index) => {
-      const ref = refMap.current.get(value);
-      return (
-        <TextField
-          size="small"
-          sx={{my: 1}}
-          key={index}
-          inputProps={{ref}}
-          autoFocus={!isNew && index === 0}
-          label={name}
-          type="text"
-          fullWidth
-          variant="outlined"
-          defaultValue={initVariables[value] ?? defaultValue}
-          InputLabelProps={{
-            shrink: true,
-          }}
-        />
-      );
+  const hasDynamicOptions = variables.some(({optionsCommand}) => Boolean(optionsCommand));
+  useEffect(() => {
+    if (!open || !hasDynamicOptions || !place) return;
+    api.templateOptions({place}).then(setDynamicOptions, (err) => {
+      console.error('fetchTemplateOptions error: %O', err);
     });
-  }, [initVariables, variables, isNew]);
+  }, [open, hasDynamicOptions, place]);
+
+  const variableInputs = useMemo(() => {
+    return variables.map(
+      ({name, value, defaultValue, type, options, isRequired, pattern, min, max}, index) => {
+        const ref = refMap.current.get(value);
+        const isSelect = type === 'enum' || type === 'boolean';
+        const selectOptions =
+          type === 'boolean' ? ['true', 'false'] : dynamicOptions[value] ?? options ?? [];
+        return (
+          <TextField
+            size="small"
+            sx={{my: 1}}
+            key={index}
+            inputProps={{
+              ref,
+              pattern: pattern || undefined,
+              min: type === 'integer' ? min : undefined,
+              max: type === 'integer' ? max : undefined,
+              step: type === 'integer' ? 1 : undefined,
+            }}
+            autoFocus={!isNew && index === 0}
+            label={name}
+            type={type === 'integer' ? 'number' : 'text'}
+            required={isRequired}
+            multiline={type === 'multiline'}
+            minRows={type === 'multiline' ? 3 : undefined}
+            select={isSelect}
+            SelectProps={isSelect ? {native: true} : undefined}
+            fullWidth
+            variant="outlined"
+            defaultValue={initVariables[value] ?? defaultValue}
+            InputLabelProps={{
+              shrink: true,
+            }}
+          >
+            {isSelect
+              ? selectOptions.map((option) => (
+                  <option key={option} value={option}>
+                    {option}
+                  </option>
+                ))
+              : null}
+          </TextField>
+        );
+      },
+    );
+  }, [initVariables, variables, isNew, dynamicOptions]);
 
   const getCommand = useCallback((isRun = false) => {
     const label = refLabel.current?.value || '';
@@ -1,4 +1,4 @@
-import React, {FC, ReactNode, useCallback, useContext, useMemo, useState} from 'react';
+import React, {FC, ReactNode, useCallback, useContext, useEffect, useMemo, useState} from 'react';
 import path from 'path-browserify';
 import {TemplatesCtx} from './TemplatesCtx';
 import {RootStoreCtx} from '../RootStore/RootStoreCtx';
@@ -21,6 +21,34 @@ const TemplateProvider: FC<TemplateProviderProps> = ({children}) => {
     setRawTemplates(templatesLocal);
   }, []);
 
+  useEffect(() => {
+    let ws: WebSocket;
+    let timeout: ReturnType<typeof setTimeout>;
+    let isClosed = false;
+
+    const connect = () => {
+      ws = new WebSocket(
+        `${location.protocol === 'http:' ? 'ws' : 'wss'}://${location.host}/ws/templates`,
+      );
+      ws.onmessage = () => {
+        updateTemplates().catch((err) => {
+          console.error('updateTemplates error: %O', err);
+        });
+      };
+      ws.onclose = () => {
+        if (isClosed) return;
+        timeout = setTimeout(connect, 5000);
+      };
+    };
+    connect();
+
+    return () => {
+      isClosed = true;
+      clearTimeout(timeout);
+      ws.close();
+    };
+  }, [updateTemplates]);
+
   const rootFolder = useMemo<TemplateFolder>(() => {
     const dirFolder = new Map<string, TemplateFolder>();
 
@@ -6,17 +6,156 @@ export interface RawTemplate {
   label?: string;
   group?: string;
   name: string;
-  variables: {
-    name: string;
-    value: string;
-    defaultValue?: string;
-  }[];
+  variables: TemplateVariable[];
+  schedules?: TemplateSchedule[];
+  extends?: string;
   isPty?: boolean;
   isOnlyCombined?: boolean;
   isWriteLogs?: boolean;
   isSingleInstance?: boolean;
   isStartOnBoot?: boolean;
   ttl?: number;
+  maxRuntime?: number;
+  stopSignal?: number;
+  stopTimeout?: number;
+  retry?: RetryPolicy | null;
+  priority?: number;
+  restart?: RestartPolicy | null;
+  probe?: TaskProbe | null;
+  resources?: TaskResources | null;
+  runAs?: TaskCredential | null;
+  workingDir?: string;
+  interpreter?: TaskInterpreter | null;
+  isReadOnly?: boolean;
+}
+
+export type TemplateVariableType =
+  | 'string'
+  | 'integer'
+  | 'boolean'
+  | 'enum'
+  | 'path'
+  | 'multiline';
+
+export interface TemplateVariable {
+  name: string;
+  value: string;
+  defaultValue?: string;
+  type?: TemplateVariableType;
+  options?: string[];
+  isRequired?: boolean;
+  pattern?: string;
+  min?: number;
+  max?: number;
+  substitution?: 'quoted' | 'env' | 'raw';
+  optionsCommand?: string;
+  optionsTimeout?: number;
+  optionsTtl?: number;
+}
+
+export interface TemplatePreset {
+  name: string;
+  variables: Record<string, string>;
+}
+
+export type TemplateRevisionAction =
+  | 'write'
+  | 'move'
+  | 'remove'
+  | 'restore'
+  | 'external';
+
+export interface TemplateRevision {
+  id: string;
+  place: string;
+  prevPlace?: string;
+  action: TemplateRevisionAction;
+  comment?: string;
+  sourceId?: string;
+  commandName: string;
+  hash: string;
+  createdAt: string;
+}
+
+export interface TemplateRevisionDiff {
+  from: string;
+  to: string;
+  template: string;
+  command: string;
+}
+
+export interface TemplateVariableError {
+  name: string;
+  error: string;
+}
+
+export interface TaskResources {
+  cpuQuota?: number;
+  memoryMax?: number;
+  pidsMax?: number;
+  ioWeight?: number;
+}
+
+export interface TaskCredential {
+  user?: string;
+  group?: string;
+  groups?: string[];
+}
+
+export interface TaskInterpreter {
+  command: string[];
+  ext?: string;
+}
+
+export interface TaskStatsSample {
+  time: string;
+  cpu: number;
+  rss: number;
+  threads: number;
+  readBytes: number;
+  writeBytes: number;
+}
+
+export interface TaskStats {
+  id: string;
+  samples: TaskStatsSample[];
+}
+
+export interface TaskProbe {
+  type: 'tcp' | 'http' | 'command';
+  address?: string;
+  url?: string;
+  command?: string;
+  initialDelay?: number;
+  interval?: number;
+  timeout?: number;
+  failureThreshold?: number;
+  isRestart?: boolean;
+}
+
+export interface RestartPolicy {
+  policy: 'never' | 'on-failure' | 'always';
+  limit?: number;
+  interval?: number;
+  backoff?: 'fixed' | 'exponential';
+  delay?: number;
+  maxDelay?: number;
+}
+
+export interface TemplateSchedule {
+  cron: string;
+  timezone?: string;
+  missedRun?: 'skip' | 'once';
+  nextRunAt?: string;
+  isPaused?: boolean;
+}
+
+export interface RetryPolicy {
+  maxAttempts: number;
+  backoff?: 'fixed' | 'exponential';
+  delay?: number;
+  maxDelay?: number;
+  exitCodes?: number[];
 }
 
 export type Template = TemplateButton | TemplateFolder;
@@ -49,8 +188,12 @@ export interface RootStore {
 
 export enum TaskState {
   Canceled = 'CANCELED',
+  Timeout = 'TIMEOUT',
   Error = 'ERROR',
   Finished = 'FINISHED',
+  Retrying = 'RETRYING',
+  Pending = 'PENDING',
+  Skipped = 'SKIPPED',
   Started = 'STARTED',
   Idle = 'IDLE',
 }
@@ -62,15 +205,96 @@ export interface TaskLink {
   title: string;
 }
 
-export interface Task extends Omit<Required<RawTemplate>, 'place' | 'name' | 'variables'> {
+type TemplateOnlyKeys =
+  | 'place'
+  | 'name'
+  | 'variables'
+  | 'schedules'
+  | 'isReadOnly'
+  | 'extends';
+
+export interface Task extends Omit<Required<RawTemplate>, TemplateOnlyKeys> {
   templatePlace: string;
   state: TaskState;
   error: string;
+  exitStatus: TaskExitStatus | null;
+  health: '' | 'READY' | 'UNHEALTHY';
+  probeFailures: number;
   createdAt: string;
+  pendingAt: string;
   startedAt: string;
   finishedAt: string;
   expiresAt: string;
   links: TaskLink[];
+  attempt: number;
+  attempts: TaskAttempt[];
+  restarts: number;
+  templateId: string;
+  templateRevision: string;
+  variables: Record<string, string> | null;
+  dependsOn: string[] | null;
+  doneDependencies?: string[];
+  templateChain?: string[];
+  env: Record<string, string> | null;
+  graph?: TaskGraph | null;
+  shim: TaskShim | null;
+}
+
+export interface TaskPreview
+  extends Omit<Required<RawTemplate>, TemplateOnlyKeys>,
+    Pick<
+      Task,
+      'templatePlace' | 'templateId' | 'templateRevision' | 'variables' | 'dependsOn' | 'env'
+    > {
+  envList: string[];
+  fullWorkDir: string;
+  errors?: TemplateVariableError[];
+}
+
+export interface DrainState {
+  isDraining: boolean;
+  running: number;
+  pending: number;
+}
+
+export interface TaskShim {
+  pid: number;
+  socket: string;
+  cgroup?: string;
+  startedAt: string;
+}
+
+export interface TaskGraphNode {
+  id: string;
+  label: string;
+  state: TaskState | '';
+  dependsOn: string[];
+}
+
+export interface TaskGraph {
+  nodes: TaskGraphNode[];
+}
+
+export interface TaskExitStatus {
+  exitCode: number;
+  signal: number;
+  signalName: string;
+  isCoreDump: boolean;
+  userTime: number;
+  systemTime: number;
+  maxRss: number;
+}
+
+export interface TaskAttempt {
+  attempt: number;
+  startedAt: string;
+  finishedAt: string;
+  isError: boolean;
+  isTimeout: boolean;
+  error: string;
+  exitStatus: TaskExitStatus | null;
+  logStart: number;
+  logEnd: number;
 }
 
 export interface PtyScreenSize {
@@ -95,6 +319,7 @@ export interface AddTaskRequest {
   templatePlace?: string;
   templateId?: string;
   variables?: Record<string, string>;
+  preset?: string;
   command?: string;
   label?: string;
   group?: string;
@@ -105,8 +330,26 @@ export interface AddTaskRequest {
   isStartOnBoot?: boolean;
   isRun?: boolean;
   ttl?: number;
+  maxRuntime?: number;
+  stopSignal?: number;
+  stopTimeout?: number;
+  retry?: RetryPolicy | null;
+  priority?: number;
+  dependsOn?: string[];
+  restart?: RestartPolicy | null;
+  probe?: TaskProbe | null;
+  resources?: TaskResources | null;
+  runAs?: TaskCredential | null;
+  workingDir?: string;
+  env?: Record<string, string>;
+  interpreter?: TaskInterpreter | null;
 }
 
 export interface CloneTaskRequest extends TaskId {
   isRun?: boolean;
 }
+
+export interface RerunTaskRequest extends TaskId {
+  variables?: Record<string, string>;
+  isRun?: boolean;
+}
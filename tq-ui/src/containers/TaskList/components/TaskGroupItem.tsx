@@ -41,9 +41,13 @@ const TaskGroupItem: FC<TaskGroupItemProps> = ({group, onUpdate}) => {
 
     return [
       TaskState.Idle,
+      TaskState.Pending,
       TaskState.Started,
+      TaskState.Retrying,
       TaskState.Finished,
       TaskState.Canceled,
+      TaskState.Skipped,
+      TaskState.Timeout,
       TaskState.Error,
     ].map((state) => {
       const count = typeCount.get(state);
@@ -121,7 +121,11 @@ const TemplatesBar: FC<TaskInputProps> = ({onUpdate}) => {
   );
 
   const handleClickTemplate = useCallback(
-    async (e: SyntheticEvent, template: TemplateButton, as?: boolean) => {
+    async (e: SyntheticEvent, button: TemplateButton, as?: boolean) => {
+      // the run dialog needs the command and variables inherited from parent templates
+      const template = button.extends
+        ? {...button, ...(await api.renderTemplate({place: button.place}))}
+        : button;
       if (!as && !template.variables.length) {
         const {place: templatePlace, isPty} = template;
         const isNewTab = 'metaKey' in e && Boolean(e.metaKey);
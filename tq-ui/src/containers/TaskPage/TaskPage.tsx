@@ -10,7 +10,13 @@ import NotificationProvider from '../../components/Notifications/NotificationPro
 import useTaskStore from '../../hooks/useTaskStore';
 import SilentStatus from '../../components/SilentStatus/SilentStatus';
 
-const completeStates = [TaskState.Finished, TaskState.Error, TaskState.Canceled];
+const completeStates = [
+  TaskState.Finished,
+  TaskState.Error,
+  TaskState.Canceled,
+  TaskState.Timeout,
+  TaskState.Skipped,
+];
 
 const TaskPage: FC = () => {
   const location = useLocation();
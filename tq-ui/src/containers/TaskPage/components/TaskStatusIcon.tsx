@@ -2,7 +2,11 @@ import React, {FC} from 'react';
 import CheckCircleOutlineIcon from '@mui/icons-material/CheckCircleOutline';
 import ErrorOutlineIcon from '@mui/icons-material/ErrorOutline';
 import BlockIcon from '@mui/icons-material/Block';
+import TimerOffIcon from '@mui/icons-material/TimerOff';
+import SkipNextIcon from '@mui/icons-material/SkipNext';
 import HourglassEmptyIcon from '@mui/icons-material/HourglassEmpty';
+import ReplayIcon from '@mui/icons-material/Replay';
+import ScheduleIcon from '@mui/icons-material/Schedule';
 import FiberManualRecordIcon from '@mui/icons-material/FiberManualRecord';
 import {SvgIconProps} from '@mui/material';
 import {Task, TaskState} from '../../../components/types';
@@ -11,6 +15,10 @@ const typeIcon = {
   [TaskState.Finished]: CheckCircleOutlineIcon,
   [TaskState.Error]: ErrorOutlineIcon,
   [TaskState.Canceled]: BlockIcon,
+  [TaskState.Timeout]: TimerOffIcon,
+  [TaskState.Skipped]: SkipNextIcon,
+  [TaskState.Retrying]: ReplayIcon,
+  [TaskState.Pending]: ScheduleIcon,
   [TaskState.Started]: HourglassEmptyIcon,
   [TaskState.Idle]: FiberManualRecordIcon,
 };
@@ -19,6 +27,10 @@ const typeColor: Record<TaskState, SvgIconProps['color']> = {
   [TaskState.Finished]: 'success',
   [TaskState.Error]: 'error',
   [TaskState.Canceled]: 'disabled',
+  [TaskState.Timeout]: 'error',
+  [TaskState.Skipped]: 'disabled',
+  [TaskState.Retrying]: 'warning',
+  [TaskState.Pending]: 'info',
   [TaskState.Started]: 'info',
   [TaskState.Idle]: 'warning',
 };
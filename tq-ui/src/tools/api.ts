@@ -1,5 +1,18 @@
 import {handleApiResponse} from './apiRequest';
-import {AddTaskRequest, Task, RawTemplate, CloneTaskRequest, TaskId} from '../components/types';
+import {
+  AddTaskRequest,
+  Task,
+  RawTemplate,
+  CloneTaskRequest,
+  RerunTaskRequest,
+  TaskId,
+  TaskStats,
+  DrainState,
+  TemplatePreset,
+  TemplateRevision,
+  TemplateRevisionDiff,
+  TaskPreview,
+} from '../components/types';
 
 interface ActionParams {
   method?: 'GET' | 'POST';
@@ -35,14 +48,25 @@ export const api = {
   task: action<TaskId, Task>({
     path: '/api/task',
   }),
+  taskStats: action<TaskId, TaskStats>({
+    path: '/api/task/stats',
+  }),
   add: action<AddTaskRequest, Task>({
     method: 'POST',
     path: '/api/add',
   }),
+  preview: action<AddTaskRequest, TaskPreview>({
+    method: 'POST',
+    path: '/api/preview',
+  }),
   clone: action<CloneTaskRequest, Task>({
     method: 'POST',
     path: '/api/clone',
   }),
+  rerun: action<RerunTaskRequest, Task>({
+    method: 'POST',
+    path: '/api/rerun',
+  }),
   delete: action<TaskId, string>({
     method: 'POST',
     path: '/api/delete',
@@ -73,6 +97,13 @@ export const api = {
     method: 'POST',
     path: '/api/task/delLink',
   }),
+  drainState: action<void, DrainState>({
+    path: '/api/drain',
+  }),
+  setDraining: action<{isDraining: boolean}, DrainState>({
+    method: 'POST',
+    path: '/api/drain',
+  }),
   reloadConfig: action<void>({
     method: 'POST',
     path: '/api/reloadConfig',
@@ -94,11 +125,34 @@ export const api = {
     method: 'GET',
     path: '/api/readTemplate',
   }),
-  setTemplate: action<{template: RawTemplate; prevPlace?: string}, string>({
+  renderTemplate: action<{place: string}, RawTemplate>({
+    method: 'GET',
+    path: '/api/renderTemplate',
+  }),
+  templateOptions: action<{place: string}, Record<string, string[]>>({
+    method: 'GET',
+    path: '/api/templateOptions',
+  }),
+  presets: action<{place: string}, TemplatePreset[]>({
+    method: 'GET',
+    path: '/api/presets',
+  }),
+  setPreset: action<{place: string; preset: TemplatePreset}, string>({
+    method: 'POST',
+    path: '/api/setPreset',
+  }),
+  removePreset: action<{place: string; name: string}, string>({
+    method: 'POST',
+    path: '/api/removePreset',
+  }),
+  setTemplate: action<
+    {template: RawTemplate; prevPlace?: string; comment?: string},
+    string
+  >({
     method: 'POST',
     path: '/api/setTemplate',
   }),
-  moveTemplate: action<{from: string; to: string}, string>({
+  moveTemplate: action<{from: string; to: string; comment?: string}, string>({
     method: 'POST',
     path: '/api/moveTemplate',
   }),
@@ -106,10 +160,28 @@ export const api = {
     method: 'POST',
     path: '/api/moveTemplateFolder',
   }),
-  removeTemplate: action<{place: string}, string>({
+  removeTemplate: action<{place: string; comment?: string}, string>({
     method: 'POST',
     path: '/api/removeTemplate',
   }),
+  templateRevisions: action<{place?: string}, TemplateRevision[]>({
+    method: 'GET',
+    path: '/api/templateRevisions',
+  }),
+  templateRevisionDiff: action<
+    {from: string; to?: string},
+    TemplateRevisionDiff
+  >({
+    method: 'GET',
+    path: '/api/templateRevisionDiff',
+  }),
+  restoreTemplateRevision: action<
+    {id: string; place?: string; comment?: string},
+    string
+  >({
+    method: 'POST',
+    path: '/api/restoreTemplateRevision',
+  }),
   getTemplateOrder: action<void, string[]>({
     method: 'GET',
     path: '/api/getTemplateOrder',